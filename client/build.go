@@ -21,6 +21,9 @@ const (
 
 const (
 	ContextCICD = "k8s-community/cicd"
+
+	// ContextConfig reports invalid configuration of a repository, build callbacks never use it
+	ContextConfig = "k8s-community/config"
 )
 
 // BuildService defines
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
+	"github.com/k8s-community/cicd"
+	"github.com/k8s-community/github-integration/config"
+	"github.com/k8s-community/github-integration/github"
 	"github.com/k8s-community/github-integration/handlers"
+	"github.com/k8s-community/github-integration/logging"
+	"github.com/k8s-community/github-integration/metrics"
+	"github.com/k8s-community/github-integration/secrets"
+	"github.com/k8s-community/github-integration/tracing"
 	_ "github.com/lib/pq" // postgresql driver
 	"github.com/takama/router"
 	"gopkg.in/reform.v1"
@@ -21,85 +32,157 @@ const (
 
 // main function
 func main() {
-	var errors []error
-
-	// Database settings
-	namespace, err := getFromEnv("POD_NAMESPACE")
+	// settings are taken from defaults, the configuration file, environment variables and flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
 	if err != nil {
-		errors = append(errors, err)
+		log.Fatalf("Couldn't load configuration: %s", err)
+	}
+
+	if cfg.PrintConfig {
+		cfg.Print(os.Stdout)
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	dbUser, err := getFromEnv("GITHUBDB_USER")
+	// the logger is set up first, DB queries are logged with it
+	logger, err := newLogger(cfg.LogLevel, cfg.LogFormat)
 	if err != nil {
-		errors = append(errors, err)
+		log.Fatalf("Couldn't set up logger: %s", err)
 	}
 
-	dbPass, err := getFromEnv("GITHUBDB_PASSWORD")
+	if err := cfg.Validate(); err != nil {
+		logger.Fatalf("Couldn't start service: %s", err)
+	}
+	logging.RedactSecrets(cfg.Secrets()...)
+
+	dataSource, err := cfg.DataSource()
 	if err != nil {
-		errors = append(errors, err)
+		logger.Fatalf("Couldn't start up DB: %s", err)
 	}
 
-	dbName, err := getFromEnv("GITHUBDB_NAME")
+	db, conn, err := startupDB(logger, dataSource)
 	if err != nil {
-		errors = append(errors, err)
+		logger.Fatalf("Couldn't start up DB: %+v", err)
 	}
 
-	dbHost := fmt.Sprintf("%s.%s", "db-github", namespace)
-	dbPort := "5432"
+	h := &handlers.Handler{
+		DB:     db,
+		Log:    logger,
+		Config: cfg,
+	}
 
-	db, err := startupDB(dbHost, dbPort, dbUser, dbPass, dbName)
+	// secrets are taken from settings or from files (GITHUBINT_TOKEN_FILE, GITHUBINT_PRIV_KEY_FILE)
+	h.WebhookSecret, err = secrets.New("GITHUBINT_TOKEN", cfg.WebhookSecret, cfg.WebhookSecretFile, nil)
 	if err != nil {
-		log.Fatalf("Couldn't start up DB: %+v", err)
+		h.Log.Fatalf("Couldn't load webhook secret: %s", err)
 	}
+	h.WebhookSecret.Overlap = cfg.SecretOverlap
 
-	if len(errors) > 0 {
-		log.Fatalf("Couldn't start service because required DB parameters are not set: %+v", errors)
+	h.PrivateKey, err = secrets.New("GITHUBINT_PRIV_KEY", cfg.PrivateKey, cfg.PrivateKeyFile, github.ValidatePrivateKey)
+	if err != nil {
+		h.Log.Fatalf("Couldn't load private key: %s", err)
 	}
 
-	keys := []string{
-		"GITHUBINT_LOCAL_PORT", "GITHUBINT_BRANCH",
-		"GITHUBINT_TOKEN", "GITHUBINT_PRIV_KEY", "GITHUBINT_INTEGRATION_ID",
-		"USERMAN_BASE_URL", "CICD_BASE_URL",
+	// secrets are never logged, the fingerprint shows which private key is loaded
+	logging.RedactSecrets(string(h.WebhookSecret.Value()), string(h.PrivateKey.Value()))
+	logKeyFingerprint(h.Log, h.PrivateKey.Value())
+
+	h.WebhookSecret.OnChange(func(value []byte) {
+		logging.RedactSecrets(string(value))
+		h.Log.Infof("GITHUBINT_TOKEN is reloaded from %s, the previous one is accepted for %s",
+			h.WebhookSecret.File(), h.WebhookSecret.Overlap)
+	})
+	h.PrivateKey.OnChange(func(value []byte) {
+		logging.RedactSecrets(string(value))
+		h.Log.Infof("GITHUBINT_PRIV_KEY is reloaded from %s", h.PrivateKey.File())
+		logKeyFingerprint(h.Log, value)
+	})
+
+	// GITHUBINT_BRANCH is kept for compatibility: deploy pushes to branches which start with it,
+	// the rest of the branch name is the version
+	rules := cfg.BranchRules
+	if rules == "" {
+		rules = cfg.Branch + "*=" + cicd.TaskDeploy + ":{{.Suffix}}"
 	}
 
-	h := &handlers.Handler{
-		DB:      db,
-		Infolog: log.New(os.Stdout, "[GITHUBINT:INFO]: ", log.LstdFlags),
-		Errlog:  log.New(os.Stderr, "[GITHUBINT:ERROR]: ", log.LstdFlags),
-		Env:     make(map[string]string, len(keys)),
+	h.BranchRules, err = handlers.ParseBranchRules(rules)
+	if err != nil {
+		h.Log.Fatalf("GITHUBINT_BRANCH_RULES is invalid: %s", err)
 	}
 
-	for _, key := range keys {
-		value := os.Getenv(key)
-		if value == "" {
-			h.Errlog.Fatalf("%s environment variable was not set", key)
+	// commands are run instead of the service
+	if len(args) > 0 {
+		switch args[0] {
+		case "sync-installations":
+			syncInstallations(h, args[1:])
+		default:
+			h.Log.Fatalf("Unknown command %s, available commands: sync-installations", args[0])
 		}
-		h.Env[key] = value
+		conn.Close()
+		return
+	}
+
+	// spans are exported only if the collector is configured, trace context is propagated anyway
+	var exporter *tracing.Exporter
+	if endpoint := cfg.OTLPEndpoint; endpoint != "" {
+		exporter = tracing.NewExporter(endpoint, "github-integration")
+		exporter.ErrorLog = h.Log.Errorf
+		tracing.SetExporter(exporter)
+		h.Log.Infof("traces are exported to %s", endpoint)
 	}
 
+	// mounted secrets are rotated without restart
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+
+	watcher := secrets.NewWatcher(cfg.SecretReload, h.WebhookSecret, h.PrivateKey)
+	watcher.ErrorLog = h.Log.Errorf
+	go watcher.Run(watchCtx)
+
+	h.Inbox = handlers.NewInbox(h, cfg.Workers, cfg.MaxAttempts, cfg.DedupRetention)
+	h.Inbox.Start()
+
 	r := router.New()
-	r.PanicHandler = handlers.Panic
+	r.PanicHandler = h.PanicHandler
 
 	r.GET("/healthz", h.HealthzHandler)
+	r.GET("/readyz", h.ReadyzHandler)
 	r.GET("/info", h.InfoHandler)
+	r.GET("/rate-limits", h.RateLimitsHandler)
+	r.GET("/metrics", h.MetricsHandler)
 
 	r.GET(apiPrefix+"/home", h.HomeHandler)
-	r.POST(apiPrefix+"/webhook", h.WebHookHandler)
+	r.POST(apiPrefix+"/webhook", handlers.Instrument("webhook", h.WebHookHandler))
 	r.POST(apiPrefix+"/auth-callback", h.AuthCallbackHandler)
-	r.POST(apiPrefix+"/build-cb", h.BuildCallbackHandler)
+	r.POST(apiPrefix+"/build-cb", handlers.Instrument("build-cb", h.BuildCallbackHandler))
 
-	r.GET(apiPrefix+"/build-results/:uuid", h.ShowBuildResults)
-	r.POST(apiPrefix+"/build-results", h.BuildResultsHandler)
+	r.GET(apiPrefix+"/build-results/:uuid", handlers.Instrument("show-build-results", h.ShowBuildResults))
+	r.POST(apiPrefix+"/build-results", handlers.Instrument("build-results", h.BuildResultsHandler))
 
 	r.NotFound = h.NotFoundHandler
 
 	//r.GET("/", h.HomeHandler)
 	//r.GET(apiPrefix+"/", h.HomeHandler)
 
-	h.Infolog.Printf("start listening port %s", h.Env["GITHUBINT_LOCAL_PORT"])
-	h.Infolog.Printf("Registered routes are: %+v", r.Routes())
+	h.Log.Infof("start listening port %d", cfg.LocalPort)
+	h.Log.Infof("Registered routes are: %+v", r.Routes())
 
-	go r.Listen(":" + h.Env["GITHUBINT_LOCAL_PORT"])
+	srv := &http.Server{
+		Addr:    ":" + strconv.Itoa(cfg.LocalPort),
+		Handler: r,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			h.Log.Fatalf("Couldn't listen port %d: %s", cfg.LocalPort, err)
+		}
+	}()
 
 	// Set up channel on which to send signal notifications.
 	// We must use a buffered channel or risk missing the signal
@@ -107,45 +190,79 @@ func main() {
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, os.Kill, syscall.SIGTERM)
 	killSignal := <-interrupt
-	h.Infolog.Println("Got signal:", killSignal)
+	h.Log.Infof("Got signal: %s", killSignal)
 
 	if killSignal == os.Kill {
-		h.Infolog.Println("Service was killed")
+		h.Log.Infof("Service was killed")
 	} else {
-		h.Infolog.Println("Service was terminated by system signal")
+		h.Log.Infof("Service was terminated by system signal")
 	}
 
-	h.Infolog.Println("shutdown")
+	// requests in progress and web hook workers must be finished before the pod is killed
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	h.Log.Infof("waiting for requests in progress")
+	if err := srv.Shutdown(ctx); err != nil {
+		h.Log.Errorf("Couldn't finish requests in progress: %s", err)
+	}
+
+	h.Log.Infof("waiting for web hook workers")
+	if err := h.Inbox.Shutdown(ctx); err != nil {
+		h.Log.Errorf("Couldn't finish web hooks in progress, they will be processed again: %s", err)
+	}
+
+	if err := conn.Close(); err != nil {
+		h.Log.Errorf("Couldn't close DB connection: %s", err)
+	}
+
+	if exporter != nil {
+		if err := exporter.Shutdown(ctx); err != nil {
+			h.Log.Errorf("Couldn't export the rest of spans: %s", err)
+		}
+	}
+
+	h.Log.Infof("shutdown")
+}
+
+// logKeyFingerprint logs the fingerprint of the private key instead of the key
+func logKeyFingerprint(logger *logging.Logger, privKey []byte) {
+	fingerprint, err := github.KeyFingerprint(privKey)
+	if err != nil {
+		logger.Errorf("GITHUBINT_PRIV_KEY can't be used: %s", err)
+		return
+	}
+	logger.Infof("GITHUBINT_PRIV_KEY fingerprint is %s", fingerprint)
 }
 
-func getFromEnv(name string) (string, error) {
-	value := os.Getenv(name)
-	if len(value) == 0 {
-		return "", fmt.Errorf("Environement variable %s must be set", name)
+// newLogger creates the logger of the level and the format
+func newLogger(level, format string) (*logging.Logger, error) {
+	l, err := logging.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("GITHUBINT_LOG_LEVEL is invalid: %s", err)
+	}
+
+	logger, err := logging.New(os.Stdout, l, format)
+	if err != nil {
+		return nil, fmt.Errorf("GITHUBINT_LOG_FORMAT is invalid: %s", err)
 	}
 
-	return value, nil
+	return logger, nil
 }
 
 // startupDB makes connection with DB, initializes reform DB level.
-func startupDB(host, port, user, password, name string) (*reform.DB, error) {
-	dataSource := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable", user, password, host, port, name,
-	)
-
+// Queries are logged at debug level. The connection is returned to be closed on shutdown.
+func startupDB(logger *logging.Logger, dataSource string) (*reform.DB, *sql.DB, error) {
 	conn, err := sql.Open("postgres", dataSource)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if err = conn.Ping(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	db := reform.NewDB(conn, postgresql.Dialect, reform.NewPrintfLogger(log.Printf))
-	if err != nil {
-		return nil, err
-	}
+	db := reform.NewDB(conn, postgresql.Dialect, metrics.NewDBLogger(logging.NewSQLLogger(logger)))
 
-	return db, nil
+	return db, conn, nil
 }
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/k8s-community/github-integration/handlers"
+)
+
+// syncInstallations runs sync-installations command: saved installations are rebuilt
+// from the installations listed by GitHub API and the changes are printed
+func syncInstallations(h *handlers.Handler, args []string) {
+	flags := flag.NewFlagSet("sync-installations", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print changes without saving them")
+	flags.Parse(args)
+
+	diff, err := h.SyncInstallations(context.Background(), *dryRun)
+	if err != nil {
+		h.Log.Fatalf("Couldn't sync installations: %s", err)
+	}
+
+	if *dryRun {
+		fmt.Println("Dry run, nothing is saved")
+	}
+
+	if diff.Empty() {
+		fmt.Println("Installations are up to date")
+		return
+	}
+
+	printChanges(os.Stdout, "Added", diff.Added)
+	printChanges(os.Stdout, "Updated", diff.Updated)
+	printChanges(os.Stdout, "Deactivated", diff.Deactivated)
+}
+
+// printChanges prints the changes of one kind
+func printChanges(w io.Writer, title string, changes []string) {
+	fmt.Fprintf(w, "%s: %d\n", title, len(changes))
+	for _, change := range changes {
+		fmt.Fprintf(w, "  %s\n", change)
+	}
+}
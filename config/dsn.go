@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// DataSource returns URL of the DB: DATABASE_URL or the URL built from GITHUBDB_* settings.
+// TLS settings are added unless the URL has them already. The DB in the cluster is connected
+// without TLS by default, DATABASE_URL without sslmode uses the default of the driver (require).
+func (c *Config) DataSource() (string, error) {
+	var u *url.URL
+	if c.DatabaseURL != "" {
+		var err error
+		u, err = url.Parse(c.DatabaseURL)
+		if err != nil {
+			return "", fmt.Errorf("couldn't parse DATABASE_URL: %s", err)
+		}
+	} else {
+		host := c.DBHost
+		if host == "" {
+			host = "db-github." + c.PodNamespace
+		}
+
+		u = &url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(c.DBUser, c.DBPassword),
+			Host:   net.JoinHostPort(host, strconv.Itoa(c.DBPort)),
+			Path:   "/" + c.DBName,
+		}
+	}
+
+	query := u.Query()
+	params := map[string]string{
+		"sslmode":     c.DBSSLMode,
+		"sslrootcert": c.DBSSLRootCert,
+		"sslcert":     c.DBSSLCert,
+		"sslkey":      c.DBSSLKey,
+	}
+	if c.DatabaseURL == "" && c.DBSSLMode == "" {
+		params["sslmode"] = "disable"
+	}
+	for key, value := range params {
+		if value != "" && query.Get(key) == "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
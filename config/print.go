@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/k8s-community/github-integration/logging"
+)
+
+// Print writes effective settings as NAME=value lines with their sources, secrets are masked
+func (c *Config) Print(w io.Writer) {
+	if c.File != "" {
+		fmt.Fprintf(w, "# configuration file: %s\n", c.File)
+	}
+
+	for _, s := range c.settings() {
+		fmt.Fprintf(w, "%s=%s # %s\n", s.env, s.masked(), c.sources[s.env])
+	}
+
+	if dsn, err := c.DataSource(); err == nil {
+		fmt.Fprintf(w, "# DB: %s\n", maskPassword(dsn))
+	}
+}
+
+// String implements fmt.Stringer, so %v and %+v of the configuration don't print secrets.
+// Receivers of String and GoString are values, so both values and pointers are masked.
+func (c Config) String() string {
+	return "{" + strings.Join(c.pairs("%s:%s"), " ") + "}"
+}
+
+// GoString implements fmt.GoStringer, so %#v doesn't print secrets too
+func (c Config) GoString() string {
+	return "config.Config{" + strings.Join(c.pairs("%s:%q"), ", ") + "}"
+}
+
+// pairs formats settings with masked secrets
+func (c *Config) pairs(format string) []string {
+	settings := c.settings()
+
+	pairs := make([]string, 0, len(settings))
+	for _, s := range settings {
+		pairs = append(pairs, fmt.Sprintf(format, s.env, s.masked()))
+	}
+
+	return pairs
+}
+
+// masked returns the value of the setting, secrets are replaced, only the password of DB URL is hidden
+func (s setting) masked() string {
+	value := fmt.Sprint(s.field.Interface())
+	if d, ok := s.field.Interface().(time.Duration); ok {
+		value = d.String()
+	}
+
+	switch {
+	case value == "":
+	case s.env == "DATABASE_URL":
+		value = maskPassword(value)
+	case s.secret:
+		value = logging.Redacted
+	}
+
+	return value
+}
+
+// maskPassword hides the password of the URL only, TLS parameters are useful to check
+func maskPassword(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return logging.Redacted
+	}
+
+	if _, ok := u.User.Password(); !ok {
+		return u.String()
+	}
+
+	// brackets of the placeholder are escaped in URL, they are restored for readability
+	u.User = url.UserPassword(u.User.Username(), logging.Redacted)
+	escaped := strings.TrimPrefix(url.UserPassword("", logging.Redacted).String(), ":")
+
+	return strings.Replace(u.String(), escaped, logging.Redacted, 1)
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMasking(t *testing.T) {
+	c := &Config{
+		WebhookSecret: "webhook-secret",
+		PrivateKey:    "private-key",
+		DBPassword:    "db-password",
+		DatabaseURL:   "postgres://user:url-password@db:5432/name?sslmode=require",
+		DBUser:        "user",
+		sources:       map[string]string{},
+	}
+
+	var buf bytes.Buffer
+	c.Print(&buf)
+
+	outputs := map[string]string{
+		"%v":    fmt.Sprintf("%v", c),
+		"%+v":   fmt.Sprintf("%+v", *c),
+		"%#v":   fmt.Sprintf("%#v", c),
+		"%s":    fmt.Sprintf("%s", *c),
+		"Print": buf.String(),
+	}
+
+	for name, out := range outputs {
+		for _, secret := range []string{"webhook-secret", "private-key", "db-password", "url-password"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%s prints %s: %s", name, secret, out)
+			}
+		}
+		if !strings.Contains(out, "postgres://user:[REDACTED]@db:5432/name?sslmode=require") {
+			t.Errorf("%s doesn't print DB URL with masked password: %s", name, out)
+		}
+		if !strings.Contains(out, "GITHUBDB_USER") {
+			t.Errorf("%s doesn't print settings: %s", name, out)
+		}
+	}
+}
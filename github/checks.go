@@ -0,0 +1,145 @@
+package github
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// checksAcceptHeader is the GitHub Checks API Preview Accept header
+const checksAcceptHeader = "application/vnd.github.antiope-preview+json"
+
+// Possible statuses of check runs
+const (
+	CheckStatusQueued     = "queued"
+	CheckStatusInProgress = "in_progress"
+	CheckStatusCompleted  = "completed"
+)
+
+// Possible conclusions of completed check runs
+const (
+	CheckConclusionSuccess        = "success"
+	CheckConclusionFailure        = "failure"
+	CheckConclusionNeutral        = "neutral"
+	CheckConclusionCancelled      = "cancelled"
+	CheckConclusionTimedOut       = "timed_out"
+	CheckConclusionActionRequired = "action_required"
+)
+
+// CheckRunOutput is a report of check run, Text may contain markdown
+type CheckRunOutput struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Text    string `json:"text,omitempty"`
+}
+
+// CheckRun is a check of a commit which is shown in the Checks tab of pull requests
+type CheckRun struct {
+	ID          int64           `json:"id,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	HeadSHA     string          `json:"head_sha,omitempty"`
+	DetailsURL  *string         `json:"details_url,omitempty"`
+	Status      string          `json:"status,omitempty"`
+	Conclusion  *string         `json:"conclusion,omitempty"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Output      *CheckRunOutput `json:"output,omitempty"`
+}
+
+// checkRunsList is a response of check runs listing
+type checkRunsList struct {
+	TotalCount int         `json:"total_count"`
+	CheckRuns  []*CheckRun `json:"check_runs"`
+}
+
+// CreateCheckRun creates a check run for the commit run.HeadSHA
+func (c *Client) CreateCheckRun(owner, repo string, run *CheckRun) (*CheckRun, error) {
+	created := new(CheckRun)
+	err := c.sendCheckRun("POST", fmt.Sprintf("repos/%s/%s/check-runs", owner, repo), run, created)
+	if err != nil {
+		return nil, fmt.Errorf("could not create check run %s for %s/%s: %w", run.Name, owner, repo, err)
+	}
+
+	return created, nil
+}
+
+// UpdateCheckRun updates the check run with the given ID
+func (c *Client) UpdateCheckRun(owner, repo string, id int64, run *CheckRun) (*CheckRun, error) {
+	// the commit of existing check run can't be changed
+	body := *run
+	body.HeadSHA = ""
+
+	updated := new(CheckRun)
+	err := c.sendCheckRun("PATCH", fmt.Sprintf("repos/%s/%s/check-runs/%d", owner, repo, id), &body, updated)
+	if err != nil {
+		return nil, fmt.Errorf("could not update check run %d for %s/%s: %w", id, owner, repo, err)
+	}
+
+	return updated, nil
+}
+
+// FindCheckRun returns the latest check run with the given name for the commit, it returns nil if there is no one
+func (c *Client) FindCheckRun(owner, repo, sha, name string) (*CheckRun, error) {
+	req, err := c.NewRequest("GET", fmt.Sprintf("repos/%s/%s/commits/%s/check-runs?check_name=%s",
+		owner, repo, sha, url.QueryEscape(name)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", checksAcceptHeader)
+
+	list := new(checkRunsList)
+	resp, err := c.DoAuthorized(req, list)
+	if err != nil {
+		return nil, fmt.Errorf("could not list check runs for %s/%s: %w", owner, repo, err)
+	}
+
+	if err := CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("could not list check runs for %s/%s: %w", owner, repo, err)
+	}
+
+	if len(list.CheckRuns) == 0 {
+		return nil, nil
+	}
+
+	return list.CheckRuns[0], nil
+}
+
+// PublishCheckRun updates existing check run with the same name for the commit or creates a new one.
+// The output replaces the previous one on update, so its text (e.g. the tail of the build log) is kept
+// if the new output has no text.
+func (c *Client) PublishCheckRun(owner, repo string, run *CheckRun) (*CheckRun, error) {
+	existing, err := c.FindCheckRun(owner, repo, run.HeadSHA, run.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		return c.CreateCheckRun(owner, repo, run)
+	}
+
+	if run.Output != nil && run.Output.Text == "" && existing.Output != nil && existing.Output.Text != "" {
+		output := *run.Output
+		output.Text = existing.Output.Text
+
+		update := *run
+		update.Output = &output
+		run = &update
+	}
+
+	return c.UpdateCheckRun(owner, repo, existing.ID, run)
+}
+
+func (c *Client) sendCheckRun(method, urlStr string, run *CheckRun, result *CheckRun) error {
+	req, err := c.NewRequest(method, urlStr, run)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", checksAcceptHeader)
+
+	resp, err := c.DoAuthorized(req, result)
+	if err != nil {
+		return err
+	}
+
+	return CheckResponse(resp)
+}
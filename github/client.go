@@ -2,20 +2,31 @@ package github
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/k8s-community/github-integration/tracing"
 )
 
 // acceptHeader is the GitHub Integrations Preview Accept header.
 const (
 	acceptHeader = "application/vnd.github.machine-man-preview+json"
-	apiBaseURL   = "https://api.github.com"
+
+	// DefaultBaseURL is URL of public GitHub API,
+	// GitHub Enterprise Server API is usually located at https://hostname/api/v3/
+	DefaultBaseURL = "https://api.github.com/"
 )
 
 // Client definess
@@ -31,7 +42,9 @@ type Client struct {
 
 	privKey []byte
 
-	token *accessToken // token is the installation's access token
+	tokens *TokenStore // tokens caches installation access tokens
+
+	ctx context.Context // ctx is the context of requests, it carries the trace
 }
 
 // accessToken is an installation access token response from GitHub
@@ -40,27 +53,78 @@ type accessToken struct {
 	ExpiresAt time.Time `json:"expires_at"`
 }
 
+// Option configures a Client
+type Option func(c *Client) error
+
+// WithBaseURL sets base URL of the API, e.g. https://github.example.com/api/v3/ for GitHub Enterprise
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) (err error) {
+		c.baseURL, err = parseBaseURL(baseURL)
+		return err
+	}
+}
+
+// WithContext sets the context of requests, API calls are traced as children of its span
+func WithContext(ctx context.Context) Option {
+	return func(c *Client) error {
+		c.ctx = ctx
+		return nil
+	}
+}
+
 // NewClient initializes a Client instance
-func NewClient(httpClient *http.Client, integrationID int, installationID int, privKey []byte) (*Client, error) {
+func NewClient(httpClient *http.Client, integrationID int, installationID int, privKey []byte, opts ...Option) (*Client, error) {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
-	baseURL, err := url.Parse(apiBaseURL)
-	if err != nil {
-		return nil, fmt.Errorf("github client: cannot parse url %s: %s", apiBaseURL, err)
-	}
 
 	c := &Client{
 		client:         httpClient,
-		baseURL:        baseURL,
 		installationID: installationID,
 		integrationID:  integrationID,
 		privKey:        privKey,
+		tokens:         defaultTokenStore,
+		ctx:            context.Background(),
+	}
+
+	opts = append([]Option{WithBaseURL(DefaultBaseURL)}, opts...)
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
 	}
 
 	return c, nil
 }
 
+// parseBaseURL parses base URL and adds trailing slash, so relative URLs are resolved inside its path
+func parseBaseURL(baseURL string) (*url.URL, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("github client: cannot parse url %s: %s", baseURL, err)
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("github client: url %s must be absolute", baseURL)
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return u, nil
+}
+
+// escapePath escapes every segment of the slash separated path, e.g. of a tag like release/1.0 or a file
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
 // NewRequest creates new http.Request instance
 func (c *Client) NewRequest(method string, urlStr string, body interface{}) (*http.Request, error) {
 	rel, err := url.Parse(urlStr)
@@ -79,7 +143,7 @@ func (c *Client) NewRequest(method string, urlStr string, body interface{}) (*ht
 		}
 	}
 
-	req, err := http.NewRequest(method, u.String(), buf)
+	req, err := http.NewRequestWithContext(c.ctx, method, u.String(), buf)
 	if err != nil {
 		return nil, fmt.Errorf("cannot send request: %s", err)
 	}
@@ -104,35 +168,123 @@ func newResponse(r *http.Response) *Response {
 // error if an API error has occurred.  If v implements the io.Writer
 // interface, the raw response body will be written to v, without attempting to
 // first decode it.
+//
+// Idempotent requests are retried with backoff after network errors, server errors and
+// rate limit errors (if the limit resets soon). RateLimitError is returned if the request
+// is rejected because of rate limit.
 func (c *Client) Do(req *http.Request, v interface{}) (*Response, error) {
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+	for attempt := 0; ; attempt++ {
+		retry := isIdempotent(req) && attempt < maxRetries
 
-	defer resp.Body.Close()
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
 
-	response := newResponse(resp)
+		_, span := tracing.Start(req.Context(), "GitHub "+req.Method, tracing.KindClient)
+		span.SetAttribute("http.method", req.Method)
+		span.SetAttribute("http.url", req.URL.Path)
+		span.SetAttribute("github.attempt", attempt)
 
-	if v != nil {
-		if w, ok := v.(io.Writer); ok {
-			io.Copy(w, resp.Body)
-		} else {
-			err = json.NewDecoder(resp.Body).Decode(v)
-			if err == io.EOF {
-				err = nil // ignore EOF errors caused by empty response body
+		start := time.Now()
+		resp, err := c.client.Do(req)
+		apiDuration.Observe(time.Since(start).Seconds(), req.Method)
+		if err != nil {
+			span.SetError(err)
+			span.End()
+			apiRequests.Inc(req.Method, "error")
+			if retry {
+				time.Sleep(retryDelay(attempt, 0))
+				continue
 			}
+			return nil, fmt.Errorf("%w: %w", ErrNetwork, err)
 		}
+
+		apiRequests.Inc(req.Method, strconv.Itoa(resp.StatusCode))
+		span.SetAttribute("http.status_code", resp.StatusCode)
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetError(fmt.Errorf("GitHub responds %s", resp.Status))
+		}
+		span.End()
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// rate limits of app (JWT) requests are not related to the installation
+		if strings.HasPrefix(req.Header.Get("Authorization"), "token ") {
+			if rate, ok := parseRate(resp); ok {
+				c.saveRate(rate)
+			}
+		}
+
+		// the body is kept for callers which check the response
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		response := newResponse(resp)
+
+		if rateErr := checkRateLimit(resp, body); rateErr != nil {
+			if retry && rateErr.RetryAfter <= maxRetryWait {
+				time.Sleep(retryDelay(attempt, rateErr.RetryAfter))
+				continue
+			}
+			return response, rateErr
+		}
+
+		if resp.StatusCode >= http.StatusInternalServerError && retry {
+			time.Sleep(retryDelay(attempt, 0))
+			continue
+		}
+
+		if v != nil && len(body) > 0 {
+			if w, ok := v.(io.Writer); ok {
+				_, err = w.Write(body)
+			} else {
+				err = json.Unmarshal(body, v)
+			}
+		}
+
+		return response, err
+	}
+}
+
+// ValidatePrivateKey checks if the private key of the integration can be used to sign JWT
+func ValidatePrivateKey(privKey []byte) error {
+	_, err := jwt.ParseRSAPrivateKeyFromPEM(privKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrBadPrivateKey, err)
+	}
+
+	return nil
+}
+
+// KeyFingerprint returns SHA-256 fingerprint of the public key of the integration private key,
+// it can be logged instead of the key to check which key is loaded
+func KeyFingerprint(privKey []byte) (string, error) {
+	parsedKey, err := jwt.ParseRSAPrivateKeyFromPEM(privKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrBadPrivateKey, err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&parsedKey.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrBadPrivateKey, err)
 	}
 
-	return response, err
+	sum := sha256.Sum256(der)
+
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
 }
 
 // generateBearer is used for JWT token generation
 func (c *Client) generateBearer() (string, error) {
 	parsedKey, err := jwt.ParseRSAPrivateKeyFromPEM(c.privKey)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("%w: %w", ErrBadPrivateKey, err)
 	}
 
 	bearer := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
@@ -147,34 +299,61 @@ func (c *Client) generateBearer() (string, error) {
 	return bearerString, err
 }
 
-// generateAccessToken is used for access token generation
-func (c *Client) generateAccessToken() error {
-	bearer, err := c.generateBearer()
+// requestAccessToken is used for access token generation
+func (c *Client) requestAccessToken() (*accessToken, error) {
+	req, err := c.NewRequest("POST", fmt.Sprintf("installations/%v/access_tokens", c.installationID), nil)
 	if err != nil {
-		return fmt.Errorf("cannot generate bearer token: %s", err)
+		return nil, fmt.Errorf("could not create request: %s", err)
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/installations/%v/access_tokens", c.baseURL, c.installationID), nil)
+	token := new(accessToken)
+	resp, err := c.DoApp(req, token)
 	if err != nil {
-		return fmt.Errorf("could not create request: %s", err)
+		return nil, fmt.Errorf("could not get access_tokens from GitHub API for installation ID %v: %w", c.installationID, err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", bearer))
-	req.Header.Set("Accept", acceptHeader)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("could not get access_tokens from GitHub API for installation ID %v: %s", c.installationID, err)
+	if err := CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("could not get access_tokens for installation ID %v: %w", c.installationID, err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode/100 != 2 {
-		return fmt.Errorf("received non 2xx response status %q when fetching %v", resp.Status, req.URL)
-	}
+	return token, nil
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&c.token); err != nil {
-		return err
+// DoAuthorized sends an API request on behalf of the installation, see Do.
+// Installation access token is taken from the token store; if GitHub rejects it (401),
+// the token is invalidated and the request is retried once with a new token.
+func (c *Client) DoAuthorized(req *http.Request, v interface{}) (*Response, error) {
+	var resp *Response
+
+	for attempt := 0; attempt < 2; attempt++ {
+		token, err := c.tokens.Token(c.installationID, c.requestAccessToken)
+		if err != nil {
+			return nil, fmt.Errorf("cannot generate access token: %w", err)
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", token.Token))
+		if req.Header.Get("Accept") == "" {
+			req.Header.Set("Accept", acceptHeader)
+		}
+
+		resp, err = c.Do(req, v)
+		if err != nil {
+			return resp, err
+		}
+
+		if resp.StatusCode != http.StatusUnauthorized {
+			break
+		}
+
+		c.tokens.Invalidate(c.installationID, token.Token)
 	}
 
-	return nil
+	return resp, nil
 }
@@ -1,10 +1,9 @@
 package github
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"net/http"
+
+	"github.com/k8s-community/github-integration/logging"
 )
 
 // BuildCallback todo: add description
@@ -18,6 +17,22 @@ type BuildCallback struct {
 	Context     *string `json:"context,omitempty"`
 }
 
+// String implements fmt.Stringer, so the callback can be logged: optional fields are dereferenced
+// and credentials in the build URL are redacted
+func (b BuildCallback) String() string {
+	s := fmt.Sprintf("%s/%s@%s state=%s", b.Username, b.Repository, b.CommitHash, b.State)
+	if b.BuildURL != nil {
+		s += " url=" + logging.RedactURL(*b.BuildURL)
+	}
+	if b.Description != nil {
+		s += fmt.Sprintf(" description=%q", logging.Redact(*b.Description))
+	}
+	if b.Context != nil {
+		s += " context=" + *b.Context
+	}
+	return s
+}
+
 // CommitStatus todo: add description
 type CommitStatus struct {
 	State       string  `json:"state"`
@@ -28,11 +43,6 @@ type CommitStatus struct {
 
 // UpdateCommitStatus todo: add description
 func (c *Client) UpdateCommitStatus(build *BuildCallback) error {
-	err := c.generateAccessToken()
-	if err != nil {
-		return fmt.Errorf("cannot generate access token: %s", err)
-	}
-
 	commitStatus := CommitStatus{
 		State:       build.State,
 		BuildURL:    build.BuildURL,
@@ -40,27 +50,18 @@ func (c *Client) UpdateCommitStatus(build *BuildCallback) error {
 		Context:     build.Context,
 	}
 
-	b := new(bytes.Buffer)
-	json.NewEncoder(b).Encode(commitStatus)
-
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s/repos/%s/%s/statuses/%s", c.baseURL, build.Username, build.Repository, build.CommitHash), b)
+	req, err := c.NewRequest("POST", fmt.Sprintf("repos/%s/%s/statuses/%s", build.Username, build.Repository, build.CommitHash), commitStatus)
 	if err != nil {
 		return fmt.Errorf("could not create request: %s", err)
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token.Token))
 
-	resp, err := c.client.Do(req)
+	resp, err := c.DoAuthorized(req, nil)
 	if err != nil {
-		return fmt.Errorf("could not update commit status from GitHub API for %s//%s: %s", build.Username, build.Repository, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode/100 != 2 {
-		return fmt.Errorf("received non 2xx response status %q when updating commit status: %s", resp.Status, req.URL)
+		return fmt.Errorf("could not update commit status from GitHub API for %s//%s: %w", build.Username, build.Repository, err)
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&c.token); err != nil {
-		return err
+	if err := CheckResponse(resp); err != nil {
+		return fmt.Errorf("could not update commit status for %s/%s: %w", build.Username, build.Repository, err)
 	}
 
 	return nil
@@ -0,0 +1,55 @@
+package github
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// contentFile is a file returned by the repository Contents API
+type contentFile struct {
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+// FileContents returns content of the file in the repository at ref (commit SHA, branch or tag).
+// It returns nil content and nil error if the file doesn't exist.
+func (c *Client) FileContents(owner, repo, path, ref string) ([]byte, error) {
+	urlStr := fmt.Sprintf("repos/%s/%s/contents/%s?ref=%s", owner, repo, escapePath(path), url.QueryEscape(ref))
+	req, err := c.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	file := new(contentFile)
+	resp, err := c.DoAuthorized(req, file)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s from %s/%s: %w", path, owner, repo, err)
+	}
+
+	if err := CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("could not get %s from %s/%s: %w", path, owner, repo, err)
+	}
+
+	if file.Type != "file" {
+		return nil, fmt.Errorf("%s in %s/%s is %s, not a file", path, owner, repo, file.Type)
+	}
+
+	if file.Encoding != "base64" {
+		return []byte(file.Content), nil
+	}
+
+	// GitHub splits base64 content by lines
+	content, err := base64.StdEncoding.DecodeString(strings.Replace(file.Content, "\n", "", -1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode %s from %s/%s: %s", path, owner, repo, err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,121 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// headerRequestID is the header with ID of the request on GitHub side, it's useful for GitHub support
+const headerRequestID = "X-GitHub-Request-Id"
+
+// Sentinel errors, use errors.Is to check errors returned by the client
+var (
+	// ErrNotFound means that the repository, the commit or another resource doesn't exist
+	// or isn't accessible for the installation
+	ErrNotFound = errors.New("github: not found")
+
+	// ErrInstallationSuspended means that the installation has been suspended by its owner
+	ErrInstallationSuspended = errors.New("github: installation suspended")
+
+	// ErrForbidden means that the installation has no permission for the request, e.g. to read repository contents
+	ErrForbidden = errors.New("github: forbidden")
+
+	// ErrBadPrivateKey means that the private key can't be parsed or GitHub rejects the app JWT
+	ErrBadPrivateKey = errors.New("github: bad private key")
+
+	// ErrUnauthorized means that GitHub rejects the installation access token
+	ErrUnauthorized = errors.New("github: unauthorized")
+
+	// ErrRateLimited means that the request is rejected because of rate limit, see RateLimitError
+	ErrRateLimited = errors.New("github: rate limit exceeded")
+
+	// ErrNetwork means that GitHub API couldn't be reached
+	ErrNetwork = errors.New("github: network error")
+)
+
+// ErrorResponse is an error returned by GitHub API
+type ErrorResponse struct {
+	Response   *http.Response `json:"-"`
+	StatusCode int            `json:"-"`
+	RequestID  string         `json:"-"`
+
+	Message          string  `json:"message"`
+	DocumentationURL string  `json:"documentation_url,omitempty"`
+	Errors           []Error `json:"errors,omitempty"`
+}
+
+// Error is a detailed validation error of ErrorResponse
+type Error struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Error implements error interface
+func (r *ErrorResponse) Error() string {
+	msg := fmt.Sprintf("%v %v: %d %s", r.Response.Request.Method, r.Response.Request.URL, r.StatusCode, r.Message)
+	for _, e := range r.Errors {
+		msg += fmt.Sprintf(" [%s.%s: %s %s]", e.Resource, e.Field, e.Code, e.Message)
+	}
+	if r.RequestID != "" {
+		msg += " (request ID " + r.RequestID + ")"
+	}
+
+	return msg
+}
+
+// Is matches the response with sentinel errors
+func (r *ErrorResponse) Is(target error) bool {
+	message := strings.ToLower(r.Message)
+
+	switch target {
+	case ErrNotFound:
+		// commit statuses for unknown SHA are rejected as unprocessable
+		return r.StatusCode == http.StatusNotFound ||
+			(r.StatusCode == http.StatusUnprocessableEntity && strings.Contains(message, "no commit found"))
+	case ErrInstallationSuspended:
+		return r.StatusCode == http.StatusForbidden && strings.Contains(message, "suspended")
+	case ErrForbidden:
+		return r.StatusCode == http.StatusForbidden
+	case ErrUnauthorized:
+		return r.StatusCode == http.StatusUnauthorized
+	}
+
+	return false
+}
+
+// Is matches RateLimitError with ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// CheckResponse returns ErrorResponse if the response status isn't 2xx
+func CheckResponse(resp *Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	errResp := &ErrorResponse{
+		Response:   resp.Response,
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(headerRequestID),
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil && len(body) > 0 {
+		if json.Unmarshal(body, errResp) != nil {
+			// not a JSON error, e.g. a page of proxy
+			errResp.Message = strings.TrimSpace(string(body))
+		}
+	}
+	if errResp.Message == "" {
+		errResp.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return errResp
+}
@@ -0,0 +1,99 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Account is a GitHub user or organization
+type Account struct {
+	ID    int    `json:"id"`
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+// Installation is an installation of the integration
+type Installation struct {
+	ID          int        `json:"id"`
+	Account     Account    `json:"account"`
+	SuspendedAt *time.Time `json:"suspended_at,omitempty"`
+}
+
+// RepositoryInstallation finds the installation which has access to the repository.
+// The request is authorized as the integration (app JWT), so installation ID of the client isn't used.
+func (c *Client) RepositoryInstallation(owner, repo string) (*Installation, error) {
+	req, err := c.NewRequest("GET", fmt.Sprintf("repos/%s/%s/installation", owner, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	inst := new(Installation)
+	resp, err := c.DoApp(req, inst)
+	if err != nil {
+		return nil, fmt.Errorf("could not get installation for %s/%s: %w", owner, repo, err)
+	}
+
+	if err := CheckResponse(resp); err != nil {
+		return nil, fmt.Errorf("could not get installation for %s/%s: %w", owner, repo, err)
+	}
+
+	return inst, nil
+}
+
+// DoApp sends an API request on behalf of the integration, see Do.
+// It's used for the integration endpoints, e.g. listing of installations.
+func (c *Client) DoApp(req *http.Request, v interface{}) (*Response, error) {
+	bearer, err := c.generateBearer()
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate bearer token: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", bearer))
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", acceptHeader)
+	}
+
+	resp, err := c.Do(req, v)
+	if err != nil {
+		return resp, err
+	}
+
+	// GitHub rejects JWT signed by a wrong key or with a wrong app ID
+	if resp.StatusCode == http.StatusUnauthorized {
+		return resp, fmt.Errorf("%w: %w", ErrBadPrivateKey, CheckResponse(resp))
+	}
+
+	return resp, nil
+}
+
+// installationsPerPage is the page size of installations listing, 100 is the maximum allowed by GitHub
+const installationsPerPage = 100
+
+// AppInstallations returns all the installations of the integration
+func (c *Client) AppInstallations() ([]*Installation, error) {
+	var installations []*Installation
+
+	for page := 1; ; page++ {
+		req, err := c.NewRequest("GET", fmt.Sprintf("app/installations?per_page=%d&page=%d", installationsPerPage, page), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var list []*Installation
+		resp, err := c.DoApp(req, &list)
+		if err != nil {
+			return nil, fmt.Errorf("could not list installations: %w", err)
+		}
+
+		if err := CheckResponse(resp); err != nil {
+			return nil, fmt.Errorf("could not list installations: %w", err)
+		}
+
+		installations = append(installations, list...)
+
+		if len(list) < installationsPerPage {
+			return installations, nil
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package github
+
+import "github.com/k8s-community/github-integration/metrics"
+
+var (
+	apiRequests = metrics.NewCounterVec("githubint_github_requests_total",
+		"GitHub API requests by method and response status code, code is \"error\" if GitHub isn't reached.",
+		"method", "code")
+
+	apiDuration = metrics.NewHistogramVec("githubint_github_request_duration_seconds",
+		"Latency of GitHub API requests by method.", nil, "method")
+
+	rateRemaining = metrics.NewGaugeVec("githubint_github_rate_limit_remaining",
+		"Remaining GitHub API requests of installation in the current rate limit window.", "installation")
+)
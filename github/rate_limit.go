@@ -0,0 +1,173 @@
+package github
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerRateLimit     = "X-RateLimit-Limit"
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+	headerRetryAfter    = "Retry-After"
+
+	// maxRetries is how many times an idempotent request is retried after rate limit or server error
+	maxRetries = 3
+
+	// maxRetryWait is the longest wait before retry, the error is returned if the limit resets later
+	maxRetryWait = time.Minute
+
+	// retryBase is the first delay before retry if GitHub doesn't tell how long to wait
+	retryBase = time.Second
+
+	// rateLimitRetryAfter is the delay after rate limit if GitHub doesn't tell how long to wait
+	rateLimitRetryAfter = time.Minute
+)
+
+// Rate is the API rate limit of an installation
+type Rate struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// RateLimitError is returned when GitHub rejects the request because of the primary
+// (hourly quota) or the secondary (abuse detection) rate limit
+type RateLimitError struct {
+	Rate       Rate
+	Secondary  bool
+	RetryAfter time.Duration
+	Message    string
+	Response   *http.Response
+}
+
+// Error implements error interface
+func (e *RateLimitError) Error() string {
+	kind := "rate limit"
+	if e.Secondary {
+		kind = "secondary rate limit"
+	}
+
+	return fmt.Sprintf("%s exceeded for %v %v, retry after %s: %s",
+		kind, e.Response.Request.Method, e.Response.Request.URL, e.RetryAfter, e.Message)
+}
+
+// rateLimits keeps the latest known rate of every installation
+var rateLimits = struct {
+	sync.Mutex
+	rates map[int]Rate
+}{rates: make(map[int]Rate)}
+
+// RateLimits returns the latest known rate limits keyed by installation ID
+func RateLimits() map[int]Rate {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+
+	rates := make(map[int]Rate, len(rateLimits.rates))
+	for id, rate := range rateLimits.rates {
+		rates[id] = rate
+	}
+
+	return rates
+}
+
+func (c *Client) saveRate(rate Rate) {
+	rateLimits.Lock()
+	defer rateLimits.Unlock()
+
+	rateLimits.rates[c.installationID] = rate
+	rateRemaining.Set(float64(rate.Remaining), strconv.Itoa(c.installationID))
+}
+
+// parseRate parses rate limit headers, ok is false if the response has no rate limit headers
+func parseRate(resp *http.Response) (rate Rate, ok bool) {
+	limit := resp.Header.Get(headerRateLimit)
+	remaining := resp.Header.Get(headerRateRemaining)
+	if limit == "" || remaining == "" {
+		return rate, false
+	}
+
+	rate.Limit, _ = strconv.Atoi(limit)
+	rate.Remaining, _ = strconv.Atoi(remaining)
+	if reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64); err == nil {
+		rate.Reset = time.Unix(reset, 0).UTC()
+	}
+
+	return rate, true
+}
+
+// checkRateLimit returns RateLimitError if the response rejects the request because of rate limit:
+// 429 is always a rate limit, 403 is a rate limit if the quota is exhausted, GitHub tells when to retry
+// or the message mentions the rate limit. RetryAfter is rateLimitRetryAfter if GitHub doesn't tell how long to wait.
+func checkRateLimit(resp *http.Response, body []byte) *RateLimitError {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	rate, _ := parseRate(resp)
+	message := string(body)
+	e := &RateLimitError{Rate: rate, Message: message, Response: resp}
+
+	// retryAfter is true if it's known how long to wait
+	var retryAfter bool
+	if seconds, err := strconv.Atoi(resp.Header.Get(headerRetryAfter)); err == nil && seconds >= 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+		retryAfter = true
+	}
+
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "secondary rate limit") || strings.Contains(lower, "abuse"):
+		e.Secondary = true
+
+	case resp.Header.Get(headerRateRemaining) == "0":
+		if !retryAfter && !rate.Reset.IsZero() {
+			// the quota has been reset already if reset time is in the past
+			e.RetryAfter = time.Until(rate.Reset)
+			if e.RetryAfter < 0 {
+				e.RetryAfter = 0
+			}
+			retryAfter = true
+		}
+
+	case retryAfter || resp.StatusCode == http.StatusTooManyRequests:
+		e.Secondary = true
+
+	case strings.Contains(lower, "rate limit"):
+		// primary rate limit without headers, e.g. behind a proxy which strips them
+
+	default:
+		// 403 which isn't related to rate limits, e.g. lack of permissions
+		return nil
+	}
+
+	if !retryAfter {
+		e.RetryAfter = rateLimitRetryAfter
+	}
+
+	return e
+}
+
+// isIdempotent checks if the request may be safely sent again
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+
+	return false
+}
+
+// retryDelay returns delay before the retry with random jitter, so concurrent clients don't retry at once
+func retryDelay(attempt int, wait time.Duration) time.Duration {
+	if wait <= 0 {
+		wait = retryBase << uint(attempt)
+	}
+
+	return wait + time.Duration(rand.Int63n(int64(wait)/2+1))
+}
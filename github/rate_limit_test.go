@@ -0,0 +1,85 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckRateLimit(t *testing.T) {
+	reset := time.Now().Add(30 * time.Minute).Unix()
+
+	tests := []struct {
+		name      string
+		status    int
+		headers   map[string]string
+		body      string
+		limited   bool
+		secondary bool
+		after     time.Duration
+	}{
+		{"success", http.StatusOK, map[string]string{headerRateRemaining: "0"}, "", false, false, 0},
+		{"permissions", http.StatusForbidden, map[string]string{headerRateRemaining: "4999"},
+			`{"message":"Resource not accessible by integration"}`, false, false, 0},
+		{"not found", http.StatusNotFound, nil, "", false, false, 0},
+
+		{"primary quota", http.StatusForbidden,
+			map[string]string{headerRateLimit: "5000", headerRateRemaining: "0", headerRateReset: strconv.FormatInt(reset, 10)},
+			`{"message":"API rate limit exceeded"}`, true, false, 30 * time.Minute},
+		{"primary quota without reset", http.StatusForbidden,
+			map[string]string{headerRateLimit: "5000", headerRateRemaining: "0"}, "", true, false, rateLimitRetryAfter},
+		{"primary quota already reset", http.StatusForbidden,
+			map[string]string{headerRateLimit: "5000", headerRateRemaining: "0", headerRateReset: "1"}, "", true, false, 0},
+		{"primary message without headers", http.StatusForbidden, nil,
+			`{"message":"API rate limit exceeded for installation ID 1."}`, true, false, rateLimitRetryAfter},
+
+		{"secondary", http.StatusForbidden, nil,
+			`{"message":"You have exceeded a secondary rate limit"}`, true, true, rateLimitRetryAfter},
+		{"secondary with retry after", http.StatusForbidden, map[string]string{headerRetryAfter: "120"},
+			`{"message":"You have exceeded a secondary rate limit"}`, true, true, 2 * time.Minute},
+		{"abuse", http.StatusForbidden, nil,
+			`{"message":"You have triggered an abuse detection mechanism"}`, true, true, rateLimitRetryAfter},
+		{"retry after only", http.StatusForbidden, map[string]string{headerRetryAfter: "5"}, "", true, true, 5 * time.Second},
+		{"retry now", http.StatusForbidden, map[string]string{headerRetryAfter: "0"}, "", true, true, 0},
+
+		{"too many requests", http.StatusTooManyRequests, nil, "", true, true, rateLimitRetryAfter},
+		{"too many requests with retry after", http.StatusTooManyRequests, map[string]string{headerRetryAfter: "3"},
+			"", true, true, 3 * time.Second},
+		{"too many requests with invalid retry after", http.StatusTooManyRequests,
+			map[string]string{headerRetryAfter: "Wed, 21 Oct 2015 07:28:00 GMT"}, "", true, true, rateLimitRetryAfter},
+	}
+
+	for _, test := range tests {
+		resp := &http.Response{
+			StatusCode: test.status,
+			Header:     http.Header{},
+			Request:    &http.Request{Method: "GET", URL: &url.URL{Path: "/repos/owner/repo"}},
+		}
+		for k, v := range test.headers {
+			resp.Header.Set(k, v)
+		}
+
+		e := checkRateLimit(resp, []byte(test.body))
+		if (e != nil) != test.limited {
+			t.Errorf("%s: expected rate limit %t, got %v", test.name, test.limited, e)
+			continue
+		}
+		if e == nil {
+			continue
+		}
+
+		if e.Secondary != test.secondary {
+			t.Errorf("%s: expected secondary %t, got %t", test.name, test.secondary, e.Secondary)
+		}
+		// reset time is rounded to seconds
+		if diff := e.RetryAfter - test.after; diff < -time.Second || diff > time.Second {
+			t.Errorf("%s: expected retry after %s, got %s", test.name, test.after, e.RetryAfter)
+		}
+		if !errors.Is(e, ErrRateLimited) {
+			t.Errorf("%s: rate limit error must match ErrRateLimited", test.name)
+		}
+	}
+}
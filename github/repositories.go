@@ -0,0 +1,41 @@
+package github
+
+import "fmt"
+
+// repositoriesPerPage is the page size of repositories listing, 100 is the maximum allowed by GitHub
+const repositoriesPerPage = 100
+
+// Repository is a repository accessible for the installation
+type Repository struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	Owner    struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// repositoriesList is a response of installation repositories listing
+type repositoriesList struct {
+	TotalCount   int           `json:"total_count"`
+	Repositories []*Repository `json:"repositories"`
+}
+
+// InstallationRepositories returns all the repositories accessible for the installation
+func (c *Client) InstallationRepositories() ([]*Repository, error) {
+	var repos []*Repository
+
+	for page := 1; ; page++ {
+		list := new(repositoriesList)
+		err := c.get(fmt.Sprintf("installation/repositories?per_page=%d&page=%d", repositoriesPerPage, page), list)
+		if err != nil {
+			return nil, fmt.Errorf("could not list repositories of installation %d: %w", c.installationID, err)
+		}
+
+		repos = append(repos, list.Repositories...)
+
+		if len(list.Repositories) < repositoriesPerPage || len(repos) >= list.TotalCount {
+			return repos, nil
+		}
+	}
+}
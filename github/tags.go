@@ -0,0 +1,63 @@
+package github
+
+import (
+	"fmt"
+)
+
+// gitObject is a target of git reference or annotated tag
+type gitObject struct {
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+// gitReference is a git reference, e.g. refs/tags/v1.0.0
+type gitReference struct {
+	Ref    string    `json:"ref"`
+	Object gitObject `json:"object"`
+}
+
+// gitTag is an annotated tag object
+type gitTag struct {
+	Tag    string    `json:"tag"`
+	Object gitObject `json:"object"`
+}
+
+// ResolveTag returns SHA of the commit which the tag points to, annotated tags are dereferenced
+func (c *Client) ResolveTag(owner, repo, tag string) (string, error) {
+	ref := new(gitReference)
+	err := c.get(fmt.Sprintf("repos/%s/%s/git/refs/tags/%s", owner, repo, escapePath(tag)), ref)
+	if err != nil {
+		return "", fmt.Errorf("cannot get reference of tag %s in %s/%s: %w", tag, owner, repo, err)
+	}
+
+	object := ref.Object
+	if object.Type == "tag" {
+		annotated := new(gitTag)
+		err = c.get(fmt.Sprintf("repos/%s/%s/git/tags/%s", owner, repo, object.SHA), annotated)
+		if err != nil {
+			return "", fmt.Errorf("cannot get annotated tag %s in %s/%s: %w", tag, owner, repo, err)
+		}
+		object = annotated.Object
+	}
+
+	if object.Type != "commit" {
+		return "", fmt.Errorf("tag %s in %s/%s points to %s, not to a commit", tag, owner, repo, object.Type)
+	}
+
+	return object.SHA, nil
+}
+
+// get requests the API resource using installation access token and decodes the response into v
+func (c *Client) get(urlStr string, v interface{}) error {
+	req, err := c.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.DoAuthorized(req, v)
+	if err != nil {
+		return err
+	}
+
+	return CheckResponse(resp)
+}
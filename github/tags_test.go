@@ -0,0 +1,85 @@
+package github
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResolveTag(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/installations/1/access_tokens":
+			w.Write([]byte(`{"token":"t1","expires_at":"` + time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+
+		case "/repos/owner/repo/git/refs/tags/v1.0.0":
+			w.Write([]byte(`{"ref":"refs/tags/v1.0.0","object":{"type":"commit","sha":"aaa"}}`))
+
+		case "/repos/owner/repo/git/refs/tags/release/1.0":
+			w.Write([]byte(`{"ref":"refs/tags/release/1.0","object":{"type":"tag","sha":"bbb"}}`))
+		case "/repos/owner/repo/git/tags/bbb":
+			w.Write([]byte(`{"tag":"release/1.0","object":{"type":"commit","sha":"ccc"}}`))
+
+		case "/repos/owner/repo/git/refs/tags/v1%231":
+			w.Write([]byte(`{"ref":"refs/tags/v1#1","object":{"type":"commit","sha":"ddd"}}`))
+
+		case "/repos/owner/repo/git/refs/tags/tree":
+			w.Write([]byte(`{"ref":"refs/tags/tree","object":{"type":"tree","sha":"eee"}}`))
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(nil, 1, 1, testPrivateKey(t), WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.tokens = NewTokenStore()
+
+	tests := []struct {
+		tag string
+		sha string
+	}{
+		{"v1.0.0", "aaa"},
+		// segments of the tag are escaped separately
+		{"release/1.0", "ccc"},
+		{"v1#1", "ddd"},
+		{"tree", ""},
+		{"unknown", ""},
+	}
+
+	for _, test := range tests {
+		sha, err := c.ResolveTag("owner", "repo", test.tag)
+		if test.sha == "" {
+			if err == nil {
+				t.Errorf("%s: expected error, got %s", test.tag, sha)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", test.tag, err)
+		} else if sha != test.sha {
+			t.Errorf("%s: expected %s, got %s", test.tag, test.sha, sha)
+		}
+	}
+}
+
+func TestEscapePath(t *testing.T) {
+	tests := map[string]string{
+		"v1.0.0":                "v1.0.0",
+		"release/1.0":           "release/1.0",
+		"a b/c?d#e":             "a%20b/c%3Fd%23e",
+		".github/pipeline.yaml": ".github/pipeline.yaml",
+	}
+
+	for path, expected := range tests {
+		if got := escapePath(path); got != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, got)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package github
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin is how long before expiration a cached token is refreshed
+const tokenExpiryMargin = time.Minute
+
+// errRefreshPanic is returned to callers which wait for a refresh which has panicked
+var errRefreshPanic = errors.New("github: access token refresh panicked")
+
+// defaultTokenStore is shared by all clients, so access tokens survive between requests
+var defaultTokenStore = NewTokenStore()
+
+// TokenStore is a concurrency-safe cache of installation access tokens keyed by installation ID
+type TokenStore struct {
+	mu      sync.Mutex
+	entries map[int]*tokenEntry
+}
+
+// tokenEntry is a cached token of an installation
+type tokenEntry struct {
+	token *accessToken
+
+	// wait is not nil while the token is being refreshed, it is closed when refresh is finished
+	wait chan struct{}
+	// err is the error of the last refresh
+	err error
+}
+
+// NewTokenStore creates an empty TokenStore
+func NewTokenStore() *TokenStore {
+	return &TokenStore{entries: make(map[int]*tokenEntry)}
+}
+
+// Token returns cached token of the installation if it doesn't expire soon, otherwise it is
+// requested by refresh. Concurrent callers wait for the single refresh of the installation.
+func (s *TokenStore) Token(installationID int, refresh func() (*accessToken, error)) (*accessToken, error) {
+	s.mu.Lock()
+
+	e, ok := s.entries[installationID]
+	if !ok {
+		e = &tokenEntry{}
+		s.entries[installationID] = e
+	}
+
+	if e.token != nil && time.Until(e.token.ExpiresAt) > tokenExpiryMargin {
+		token := e.token
+		s.mu.Unlock()
+		return token, nil
+	}
+
+	if e.wait != nil {
+		wait := e.wait
+		s.mu.Unlock()
+		<-wait
+
+		s.mu.Lock()
+		token, err := e.token, e.err
+		s.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return token, nil
+	}
+
+	wait := make(chan struct{})
+	e.wait = wait
+	s.mu.Unlock()
+
+	// waiters are released even if refresh panics, otherwise they and later callers would block forever
+	var (
+		token *accessToken
+		err   = errRefreshPanic
+	)
+	defer func() {
+		s.mu.Lock()
+		e.wait = nil
+		e.err = err
+		if err == nil {
+			e.token = token
+		}
+		close(wait)
+		s.mu.Unlock()
+	}()
+
+	token, err = refresh()
+
+	return token, err
+}
+
+// Invalidate removes the token of the installation from cache, e.g. when GitHub rejects it.
+// The token is removed only if it is still cached, so a fresh token isn't dropped by a late response.
+func (s *TokenStore) Invalidate(installationID int, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[installationID]; ok && e.token != nil && e.token.Token == token {
+		e.token = nil
+	}
+}
@@ -0,0 +1,246 @@
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTokenStoreSingleRefresh(t *testing.T) {
+	store := NewTokenStore()
+
+	var (
+		mu        sync.Mutex
+		refreshes int
+		release   = make(chan struct{})
+	)
+	refresh := func() (*accessToken, error) {
+		mu.Lock()
+		refreshes++
+		mu.Unlock()
+
+		<-release
+		return &accessToken{Token: "t1", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	}
+
+	const callers = 20
+
+	var wg sync.WaitGroup
+	tokens := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			token, err := store.Token(1, refresh)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			tokens[i] = token.Token
+		}(i)
+	}
+
+	// callers wait for the refresh in progress
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if refreshes != 1 {
+		t.Errorf("expected one refresh, got %d", refreshes)
+	}
+	for i, token := range tokens {
+		if token != "t1" {
+			t.Errorf("caller %d: expected t1, got %q", i, token)
+		}
+	}
+}
+
+func TestTokenStoreExpiry(t *testing.T) {
+	store := NewTokenStore()
+
+	var refreshes int
+	refresh := func(expiresIn time.Duration) func() (*accessToken, error) {
+		return func() (*accessToken, error) {
+			refreshes++
+			return &accessToken{Token: "t" + string(rune('0'+refreshes)), ExpiresAt: time.Now().Add(expiresIn)}, nil
+		}
+	}
+
+	// the token which expires within the margin is refreshed on the next call
+	if token, _ := store.Token(1, refresh(tokenExpiryMargin/2)); token.Token != "t1" {
+		t.Fatalf("expected t1, got %s", token.Token)
+	}
+	if token, _ := store.Token(1, refresh(time.Hour)); token.Token != "t2" {
+		t.Fatalf("expiring token must be refreshed, got %s", token.Token)
+	}
+	if token, _ := store.Token(1, refresh(time.Hour)); token.Token != "t2" {
+		t.Fatalf("valid token must be cached, got %s", token.Token)
+	}
+
+	// tokens of installations are independent
+	if token, _ := store.Token(2, refresh(time.Hour)); token.Token != "t3" {
+		t.Fatalf("expected t3 for another installation, got %s", token.Token)
+	}
+}
+
+func TestTokenStoreRefreshError(t *testing.T) {
+	store := NewTokenStore()
+	failure := errors.New("github: network error")
+
+	_, err := store.Token(1, func() (*accessToken, error) { return nil, failure })
+	if err != failure {
+		t.Fatalf("expected refresh error, got %v", err)
+	}
+
+	// failed refresh isn't cached
+	token, err := store.Token(1, func() (*accessToken, error) {
+		return &accessToken{Token: "t1", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	})
+	if err != nil || token.Token != "t1" {
+		t.Errorf("expected t1 after failed refresh, got %v, %v", token, err)
+	}
+}
+
+func TestTokenStoreRefreshPanic(t *testing.T) {
+	store := NewTokenStore()
+	started := make(chan struct{})
+
+	// the caller waits for the refresh which panics
+	waiter := make(chan error)
+	go func() {
+		<-started
+		_, err := store.Token(1, func() (*accessToken, error) {
+			return nil, errors.New("waiter must not refresh")
+		})
+		waiter <- err
+	}()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic of refresh must be propagated")
+			}
+		}()
+
+		store.Token(1, func() (*accessToken, error) {
+			close(started)
+			time.Sleep(50 * time.Millisecond)
+			panic("refresh failed")
+		})
+	}()
+
+	select {
+	case err := <-waiter:
+		if err != errRefreshPanic {
+			t.Errorf("expected %v, got %v", errRefreshPanic, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiter is blocked after panic of refresh")
+	}
+
+	// the next caller refreshes the token
+	token, err := store.Token(1, func() (*accessToken, error) {
+		return &accessToken{Token: "t1", ExpiresAt: time.Now().Add(time.Hour)}, nil
+	})
+	if err != nil || token.Token != "t1" {
+		t.Errorf("expected t1 after panic of refresh, got %v, %v", token, err)
+	}
+}
+
+func TestTokenStoreInvalidate(t *testing.T) {
+	store := NewTokenStore()
+	fetch := func(value string) func() (*accessToken, error) {
+		return func() (*accessToken, error) {
+			return &accessToken{Token: value, ExpiresAt: time.Now().Add(time.Hour)}, nil
+		}
+	}
+
+	store.Token(1, fetch("t1"))
+	store.Invalidate(1, "t1")
+	if token, _ := store.Token(1, fetch("t2")); token.Token != "t2" {
+		t.Fatalf("invalidated token must be refreshed, got %s", token.Token)
+	}
+
+	// late rejection of the previous token doesn't drop the fresh one
+	store.Invalidate(1, "t1")
+	if token, _ := store.Token(1, fetch("t3")); token.Token != "t2" {
+		t.Errorf("fresh token must be kept, got %s", token.Token)
+	}
+}
+
+func TestDoAuthorizedUnauthorized(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		issued  int
+		rejects int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/installations/1/access_tokens":
+			issued++
+			json.NewEncoder(w).Encode(accessToken{Token: "t" + string(rune('0'+issued)), ExpiresAt: time.Now().Add(time.Hour)})
+
+		case "/repos/owner/repo":
+			// the first token has been revoked
+			if r.Header.Get("Authorization") == "token t1" {
+				rejects++
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"message":"Bad credentials"}`))
+				return
+			}
+			w.Write([]byte(`{"id":1}`))
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(nil, 1, 1, testPrivateKey(t), WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.tokens = NewTokenStore()
+
+	for i := 0; i < 2; i++ {
+		req, err := c.NewRequest("GET", "repos/owner/repo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := c.DoAuthorized(req, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("request %d: expected 200 with a new token, got %d", i, resp.StatusCode)
+		}
+	}
+
+	// the rejected token is replaced once, then the new one is cached
+	if issued != 2 || rejects != 1 {
+		t.Errorf("expected 2 tokens and 1 rejection, got %d and %d", issued, rejects)
+	}
+}
+
+// testPrivateKey generates PEM encoded private key to sign app JWT
+func testPrivateKey(t *testing.T) []byte {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
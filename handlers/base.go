@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"strconv"
 
+	"github.com/k8s-community/github-integration/config"
 	"github.com/k8s-community/github-integration/github"
+	"github.com/k8s-community/github-integration/logging"
+	"github.com/k8s-community/github-integration/metrics"
+	"github.com/k8s-community/github-integration/secrets"
+	"github.com/k8s-community/github-integration/tracing"
 	"github.com/k8s-community/github-integration/version"
 	"github.com/takama/router"
 	"gopkg.in/reform.v1"
@@ -14,10 +19,26 @@ import (
 
 // Handler defines
 type Handler struct {
-	DB      *reform.DB
-	Infolog *log.Logger
-	Errlog  *log.Logger
-	Env     map[string]string
+	DB     *reform.DB
+	Log    *logging.Logger
+	Config *config.Config
+	Inbox  *Inbox
+
+	// PrivateKey of the integration (GITHUBINT_PRIV_KEY) and WebhookSecret (GITHUBINT_TOKEN) may be rotated
+	PrivateKey    *secrets.Secret
+	WebhookSecret *secrets.Secret
+
+	// BranchRules route pushes to CICD tasks
+	BranchRules []BranchRule
+}
+
+// keyFingerprint returns the fingerprint of GITHUBINT_PRIV_KEY to be logged instead of the key
+func (h *Handler) keyFingerprint() string {
+	fingerprint, err := github.KeyFingerprint(h.PrivateKey.Value())
+	if err != nil {
+		return "invalid key"
+	}
+	return fingerprint
 }
 
 // NotFoundHandler handles all the wrong routes
@@ -40,7 +61,7 @@ func (h *Handler) AuthCallbackHandler(c *router.Control) {
 	fmt.Fprint(c.Writer, "The full URL to redirect to after a user authorizes an installation.")
 }
 
-// HealthzHandler todo: add description
+// HealthzHandler is a cheap liveness check, dependencies are checked by ReadyzHandler
 func (h *Handler) HealthzHandler(c *router.Control) {
 	c.Code(http.StatusOK).Body("Ok")
 }
@@ -56,28 +77,128 @@ func (h *Handler) InfoHandler(c *router.Control) {
 	)
 }
 
+// RateLimitsHandler shows the latest known GitHub API rate limits keyed by installation ID
+func (h *Handler) RateLimitsHandler(c *router.Control) {
+	c.Code(http.StatusOK).Body(github.RateLimits())
+}
+
 func (h *Handler) updateCommitStatus(c *router.Control, build *github.BuildCallback) error {
-	installationID, err := h.installationID(build.Username)
+	ctx, build, instID, err := h.statusTarget(c.Request.Context(), build)
 	if err != nil {
+		c.Code(http.StatusInternalServerError).Body(nil)
+		return fmt.Errorf("couldn't find build dispatch for %s/%s: %s", build.Username, build.Repository, err)
+	}
+
+	inst, err := h.installation(ctx, instID, build.Username, build.Repository)
+	if errors.Is(err, errInstallationInactive) {
+		c.Code(http.StatusForbidden).Body(nil)
+		return fmt.Errorf("couldn't update commit status: %w", err)
+	}
+	if err == reform.ErrNoRows {
 		c.Code(http.StatusNotFound).Body(nil)
 		return fmt.Errorf("couldn't find installation for %s", build.Username)
 	}
+	if err != nil {
+		// the installation is resolved via GitHub API if it isn't saved
+		c.Code(githubErrorCode(err)).Body(nil)
+		return fmt.Errorf("couldn't find installation for %s: %w", build.Username, err)
+	}
+
+	ctx = h.withLog(ctx, "installation", inst.InstallationID)
 
-	privKey := []byte(h.Env["GITHUBINT_PRIV_KEY"])
-	integrationID, err := strconv.Atoi(h.Env["GITHUBINT_INTEGRATION_ID"])
+	// the account may be renamed after the build is requested
+	target := *build
+	target.Username = inst.Username
+	build = &target
 
-	client, err := github.NewClient(nil, integrationID, *installationID, privKey)
+	client, err := h.githubClient(ctx, inst.InstallationID)
 	if err != nil {
 		c.Code(http.StatusInternalServerError).Body(nil)
 		return fmt.Errorf("couldn't init client for github: %s", err)
 	}
 
-	err = client.UpdateCommitStatus(build)
+	err = h.reportBuild(ctx, client, build)
 	if err != nil {
-		c.Code(http.StatusInternalServerError).Body(nil)
-		h.Errlog.Printf("GITHUBINT_PRIV_KEY is %v", privKey)
-		return fmt.Errorf("couldn't update commit status: %s", err)
+		c.Code(githubErrorCode(err)).Body(nil)
+		if errors.Is(err, github.ErrBadPrivateKey) || errors.Is(err, github.ErrUnauthorized) {
+			h.log(ctx).Errorf("GitHub rejects GITHUBINT_PRIV_KEY with fingerprint %s", h.keyFingerprint())
+		}
+		return fmt.Errorf("couldn't update commit status: %w", err)
 	}
 
 	return nil
 }
+
+// githubClient creates GitHub API client on behalf of the installation
+func (h *Handler) githubClient(ctx context.Context, installationID int) (*github.Client, error) {
+	return github.NewClient(nil, h.Config.IntegrationID, installationID, h.PrivateKey.Value(), h.githubOptions(ctx)...)
+}
+
+// githubOptions points GitHub API clients to GITHUBINT_API_URL,
+// requests of the clients are traced within the context
+func (h *Handler) githubOptions(ctx context.Context) []github.Option {
+	return []github.Option{
+		github.WithBaseURL(h.Config.APIURL),
+		github.WithContext(ctx),
+	}
+}
+
+// db returns the DB handle which records queries as spans of the context,
+// queries are logged with fields of the context logger
+func (h *Handler) db(ctx context.Context) *reform.DB {
+	next := metrics.NewDBLogger(logging.NewSQLLogger(h.log(ctx)))
+	return reform.NewDBFromInterface(h.DB.DBInterface(), h.DB.Dialect, tracing.NewDBLogger(ctx, next))
+}
+
+// log returns the logger of the context (it has fields of the hook being processed) or h.Log,
+// lines are correlated with the trace of the context
+func (h *Handler) log(ctx context.Context) *logging.Logger {
+	l := logging.FromContext(ctx)
+	if l == nil {
+		l = h.Log
+	}
+
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		l = l.With("trace_id", sc.TraceID.String())
+	}
+
+	return l
+}
+
+// withLog returns a copy of ctx where the logger has the fields (key, value pairs)
+func (h *Handler) withLog(ctx context.Context, keyvals ...interface{}) context.Context {
+	l := logging.FromContext(ctx)
+	if l == nil {
+		l = h.Log
+	}
+
+	return logging.NewContext(ctx, l.With(keyvals...))
+}
+
+// githubErrorCode maps an error of GitHub API client to the HTTP status code of response
+func githubErrorCode(err error) int {
+	switch {
+	case errors.Is(err, github.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, github.ErrInstallationSuspended):
+		return http.StatusForbidden
+	case errors.Is(err, github.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, github.ErrNetwork):
+		return http.StatusBadGateway
+	case errors.Is(err, github.ErrBadPrivateKey), errors.Is(err, github.ErrUnauthorized):
+		return http.StatusInternalServerError
+	}
+
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) {
+		switch {
+		case errResp.StatusCode == http.StatusUnprocessableEntity:
+			return http.StatusUnprocessableEntity
+		case errResp.StatusCode >= http.StatusInternalServerError:
+			return http.StatusBadGateway
+		}
+	}
+
+	return http.StatusInternalServerError
+}
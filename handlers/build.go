@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 
 	"github.com/k8s-community/github-integration/github"
 	"github.com/takama/router"
@@ -11,29 +12,34 @@ import (
 
 // BuildCallbackHandler is handler for callback from build service (system)
 func (h *Handler) BuildCallbackHandler(c *router.Control) {
-	h.Infolog.Print("Received callback request...")
+	h.log(c.Request.Context()).Infof("received callback request")
 
 	var build github.BuildCallback
 
 	body, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
-		h.Errlog.Printf("couldn't read request body: %s", err)
+		h.log(c.Request.Context()).Errorf("couldn't read request body: %s", err)
 		c.Code(http.StatusBadRequest).Body(nil)
 		return
 	}
 
 	err = json.Unmarshal(body, &build)
 	if err != nil {
-		h.Errlog.Printf("couldn't validate request body: %s", err)
+		h.log(c.Request.Context()).Errorf("couldn't validate request body: %s", err)
 		c.Code(http.StatusBadRequest).Body(nil)
 		return
 	}
 
+	ctx := h.withLog(c.Request.Context(), "repo", build.Username+"/"+build.Repository, "commit", build.CommitHash)
+	c.Request = c.Request.WithContext(ctx)
+
 	err = h.updateCommitStatus(c, &build)
 	if err != nil {
-		h.Errlog.Printf("cannot update commit status, build: %+v, err: %s", build, err)
+		h.log(ctx).Errorf("cannot update commit status, state %s: %s", build.State, err)
+		commitStatusUpdates.Inc(build.State, strconv.Itoa(c.GetCode()))
 		return
 	}
 
+	commitStatusUpdates.Inc(build.State, strconv.Itoa(http.StatusOK))
 	c.Code(http.StatusOK).Body(nil)
 }
@@ -13,19 +13,20 @@ import (
 
 // BuildResultsHandler handles and stores results of the building process.
 func (h *Handler) BuildResultsHandler(c *router.Control) {
-	h.Infolog.Print("Received build-results request...")
+	ctx := c.Request.Context()
+	h.log(ctx).Infof("received build-results request")
 
 	var build client.BuildResults
 	body, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
-		h.Errlog.Printf("couldn't read request body: %s", err)
+		h.log(ctx).Errorf("couldn't read request body: %s", err)
 		c.Code(http.StatusBadRequest).Body(nil)
 		return
 	}
 
 	err = json.Unmarshal(body, &build)
 	if err != nil {
-		h.Errlog.Printf("couldn't validate request body: %s", err)
+		h.log(ctx).Errorf("couldn't validate request body: %s", err)
 		c.Code(http.StatusBadRequest).Body(nil)
 		return
 	}
@@ -38,27 +39,35 @@ func (h *Handler) BuildResultsHandler(c *router.Control) {
 		Passed:     build.Passed,
 		Log:        build.Log,
 	}
-	err = h.DB.Save(result)
+	ctx = h.withLog(ctx, "repo", build.Username+"/"+build.Repository, "commit", build.CommitHash, "build", build.UUID)
+	err = h.db(ctx).Save(result)
 
 	if err != nil {
-		h.Errlog.Printf("Couldn't save results of build: '%+v', build: '%v'", err, result)
+		h.log(ctx).Errorf("Couldn't save results of build: %s", err)
 		c.Code(http.StatusInternalServerError).Body("Couldn't save results of build " + build.UUID)
 		return
 	}
 
+	err = h.publishBuildResults(ctx, result)
+	if err != nil {
+		h.log(ctx).Errorf("Couldn't publish results of build as check run: %s", err)
+	}
+
 	c.Code(http.StatusCreated).Body("Document uuid: " + build.UUID)
 }
 
 func (h *Handler) ShowBuildResults(c *router.Control) {
 	uuid := c.Get(":uuid")
-	st, err := h.DB.FindOneFrom(models.BuildTable, "uuid", uuid)
+	ctx := h.withLog(c.Request.Context(), "build", uuid)
+
+	st, err := h.db(ctx).FindOneFrom(models.BuildTable, "uuid", uuid)
 	if err != nil && err != reform.ErrNoRows {
-		h.Errlog.Print(err)
+		h.log(ctx).Errorf("couldn't find build results: %s", err)
 		c.Code(http.StatusInternalServerError).Body(nil)
 		return
 	}
 	if err == reform.ErrNoRows {
-		h.Errlog.Print(err)
+		h.log(ctx).Infof("build results are not found")
 		c.Code(http.StatusNotFound).Body(nil)
 		return
 	}
@@ -68,7 +77,7 @@ func (h *Handler) ShowBuildResults(c *router.Control) {
 
 	err = json.NewEncoder(c.Writer).Encode(bld)
 	if err != nil {
-		h.Errlog.Print(err)
+		h.log(ctx).Errorf("couldn't encode build results: %s", err)
 		c.Code(http.StatusInternalServerError).Body(nil)
 		return
 	}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/k8s-community/github-integration/client"
+	"github.com/k8s-community/github-integration/config"
+	"github.com/k8s-community/github-integration/github"
+	"github.com/k8s-community/github-integration/models"
+)
+
+const (
+	// buildResultsPath is the path of stored build results relative to GITHUBINT_PUBLIC_URL
+	buildResultsPath = "/api/v1/build-results/"
+
+	// logTailLines and logTailSize limit the build log shown in check run output
+	logTailLines = 100
+	logTailSize  = 60000
+)
+
+// reportBuild reports the build callback as GITHUBINT_REPORTER defines: as a check run, a commit status or both.
+// Commit status is used if the check run can't be published.
+func (h *Handler) reportBuild(ctx context.Context, gh *github.Client, build *github.BuildCallback) error {
+	reporter := h.Config.Reporter
+	if reporter != config.ReporterStatuses {
+		err := h.publishCheckRun(gh, build)
+		if err == nil && reporter == config.ReporterChecks {
+			return nil
+		}
+		if err != nil {
+			h.log(ctx).Warnf("couldn't publish check run for %s/%s, commit status is used: %s",
+				build.Username, build.Repository, err)
+		}
+	}
+
+	return gh.UpdateCommitStatus(build)
+}
+
+// publishCheckRun reports the build callback as a check run named by the status context
+func (h *Handler) publishCheckRun(gh *github.Client, build *github.BuildCallback) error {
+	run := &github.CheckRun{
+		Name:       client.ContextCICD,
+		HeadSHA:    build.CommitHash,
+		DetailsURL: build.BuildURL,
+		Output: &github.CheckRunOutput{
+			Title:   "Build " + build.State,
+			Summary: fmt.Sprintf("The build is %s.", build.State),
+		},
+	}
+	if build.Context != nil && *build.Context != "" {
+		run.Name = *build.Context
+	}
+	if build.Description != nil && *build.Description != "" {
+		run.Output.Summary = *build.Description
+	}
+
+	now := time.Now().UTC()
+	switch build.State {
+	case client.StatePending:
+		run.Status = github.CheckStatusInProgress
+		run.StartedAt = &now
+	case client.StateSuccess:
+		completeCheckRun(run, github.CheckConclusionSuccess, now)
+	case client.StateFailure, client.StateError:
+		completeCheckRun(run, github.CheckConclusionFailure, now)
+	default:
+		return fmt.Errorf("unknown build state %q", build.State)
+	}
+
+	_, err := gh.PublishCheckRun(build.Username, build.Repository, run)
+	return err
+}
+
+// publishBuildResults reports stored build results with the tail of the build log as a check run
+func (h *Handler) publishBuildResults(ctx context.Context, build *models.Build) error {
+	if h.Config.Reporter == config.ReporterStatuses {
+		return nil
+	}
+
+	ctx, target, instID, err := h.statusTarget(ctx, &github.BuildCallback{
+		Username:   build.Username,
+		Repository: build.Repository,
+		CommitHash: build.Commit,
+	})
+	if err != nil {
+		return err
+	}
+
+	inst, err := h.installation(ctx, instID, target.Username, target.Repository)
+	if err != nil {
+		return fmt.Errorf("couldn't find installation for %s: %w", target.Username, err)
+	}
+	target.Username = inst.Username
+
+	gh, err := h.githubClient(ctx, inst.InstallationID)
+	if err != nil {
+		return fmt.Errorf("couldn't init client for github: %s", err)
+	}
+
+	run := &github.CheckRun{
+		Name:    client.ContextCICD,
+		HeadSHA: build.Commit,
+		Output: &github.CheckRunOutput{
+			Title:   "Build failed",
+			Summary: "The build has failed, see the tail of the build log below.",
+			Text:    "```\n" + logTail(build.Log) + "\n```",
+		},
+	}
+	if target.Context != nil && *target.Context != "" {
+		run.Name = *target.Context
+	}
+	if publicURL := h.Config.PublicURL; publicURL != "" {
+		detailsURL := strings.TrimRight(publicURL, "/") + buildResultsPath + build.UUID
+		run.DetailsURL = &detailsURL
+	}
+
+	conclusion := github.CheckConclusionFailure
+	if build.Passed {
+		conclusion = github.CheckConclusionSuccess
+		run.Output.Title = "Build passed"
+		run.Output.Summary = "The build has passed, see the tail of the build log below."
+	}
+	completeCheckRun(run, conclusion, time.Now().UTC())
+
+	_, err = gh.PublishCheckRun(target.Username, target.Repository, run)
+	return err
+}
+
+func completeCheckRun(run *github.CheckRun, conclusion string, at time.Time) {
+	run.Status = github.CheckStatusCompleted
+	run.Conclusion = &conclusion
+	run.CompletedAt = &at
+}
+
+// logTail returns the last lines of the log which fit into check run output
+func logTail(log string) string {
+	log = strings.TrimRight(log, "\n")
+
+	lines := strings.Split(log, "\n")
+	if len(lines) > logTailLines {
+		lines = lines[len(lines)-logTailLines:]
+	}
+	tail := strings.Join(lines, "\n")
+
+	if len(tail) > logTailSize {
+		tail = tail[len(tail)-logTailSize:]
+	}
+
+	// the log must not close the code block
+	return strings.Replace(tail, "```", "` ` `", -1)
+}
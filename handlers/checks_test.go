@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/k8s-community/github-integration/client"
+	"github.com/k8s-community/github-integration/config"
+	"github.com/k8s-community/github-integration/github"
+)
+
+func TestReportBuild(t *testing.T) {
+	tests := []struct {
+		reporter        string
+		checksForbidden bool
+		checkRun        bool
+		status          bool
+	}{
+		{config.ReporterChecks, false, true, false},
+		// commit status is used if the installation has no Checks permission
+		{config.ReporterChecks, true, false, true},
+		{config.ReporterStatuses, false, false, true},
+		{config.ReporterBoth, false, true, true},
+		{config.ReporterBoth, true, false, true},
+	}
+
+	for _, test := range tests {
+		gh := newFakeGitHub(t)
+		gh.checksForbidden = test.checksForbidden
+		h := testHandler(t, gh.URL, test.reporter)
+		name := fmt.Sprintf("%s (checks forbidden %t)", test.reporter, test.checksForbidden)
+
+		gc, err := h.githubClient(context.Background(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		statusContext := client.ContextCICD
+		err = h.reportBuild(context.Background(), gc, &github.BuildCallback{
+			Username:   "owner",
+			Repository: "repo",
+			CommitHash: "sha",
+			State:      client.StateSuccess,
+			Context:    &statusContext,
+		})
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+
+		run := gh.checkRun(client.ContextCICD)
+		if (run != nil) != test.checkRun {
+			t.Errorf("%s: expected check run %t, got %+v", name, test.checkRun, run)
+		}
+		if run != nil && (run.Conclusion == nil || *run.Conclusion != github.CheckConclusionSuccess) {
+			t.Errorf("%s: expected successful check run, got %+v", name, run)
+		}
+
+		state := gh.status(client.ContextCICD)
+		if (state != "") != test.status {
+			t.Errorf("%s: expected commit status %t, got %q", name, test.status, state)
+		}
+		if state != "" && state != client.StateSuccess {
+			t.Errorf("%s: expected successful commit status, got %q", name, state)
+		}
+	}
+}
+
+func TestReportBuildKeepsLog(t *testing.T) {
+	gh := newFakeGitHub(t)
+	h := testHandler(t, gh.URL, config.ReporterChecks)
+
+	gc, err := h.githubClient(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// build results are published before the final callback of the build
+	_, err = gc.PublishCheckRun("owner", "repo", &github.CheckRun{
+		Name:    client.ContextCICD,
+		HeadSHA: "sha",
+		Output:  &github.CheckRunOutput{Title: "Build passed", Summary: "The build has passed.", Text: "```\nok\n```"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = h.reportBuild(context.Background(), gc, &github.BuildCallback{
+		Username:   "owner",
+		Repository: "repo",
+		CommitHash: "sha",
+		State:      client.StateSuccess,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := gh.checkRun(client.ContextCICD)
+	if run == nil || run.Output == nil || run.Output.Text != "```\nok\n```" {
+		t.Errorf("the build log must be kept, got %+v", run.Output)
+	}
+	if run.Output.Title != "Build success" {
+		t.Errorf("the title must be updated, got %q", run.Output.Title)
+	}
+}
+
+func TestLogTail(t *testing.T) {
+	var long []string
+	for i := 1; i <= logTailLines+10; i++ {
+		long = append(long, fmt.Sprintf("line %d", i))
+	}
+	var expected []string
+	for i := 11; i <= logTailLines+10; i++ {
+		expected = append(expected, fmt.Sprintf("line %d", i))
+	}
+
+	wide := strings.Repeat("x", logTailSize+10)
+
+	tests := []struct {
+		name string
+		log  string
+		tail string
+	}{
+		{"empty", "", ""},
+		{"short", "step 1\nstep 2\n\n", "step 1\nstep 2"},
+		{"last lines", strings.Join(long, "\n"), strings.Join(expected, "\n")},
+		{"last bytes", "first\n" + wide, wide[10:]},
+		{"code block", "```\nrm -rf /\n```", "` ` `\nrm -rf /\n` ` `"},
+	}
+
+	for _, test := range tests {
+		if tail := logTail(test.log); tail != test.tail {
+			t.Errorf("%s: expected %q, got %q", test.name, test.tail, tail)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/k8s-community/cicd"
+	"github.com/k8s-community/cicd/utils/rest"
+	"github.com/k8s-community/github-integration/github"
+	"github.com/k8s-community/github-integration/models"
+	"github.com/k8s-community/github-integration/tracing"
+	"gopkg.in/reform.v1"
+)
+
+// cicdBuildURL is the Build API method of CICD service
+const cicdBuildURL = "/api/v1/build"
+
+// servicesTimeout limits requests to CICD and user-manager services
+const servicesTimeout = 30 * time.Second
+
+// servicesClient is the HTTP client of CICD and user-manager services
+var servicesClient = &http.Client{Timeout: servicesTimeout}
+
+// runBuild sends the build request to CICD service
+func (h *Handler) runBuild(ctx context.Context, req *cicd.BuildRequest) (*cicd.BuildResponse, error) {
+	client := rest.NewClient(servicesClient, h.Config.CICDBaseURL)
+
+	httpReq, err := client.NewRequest("POST", cicdBuildURL, req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	tracing.Inject(ctx, httpReq.Header)
+
+	response := new(cicd.BuildResponse)
+	resp, err := client.Do(httpReq, response)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		if response.Error != nil {
+			return nil, fmt.Errorf("code %d, %s", response.Error.Code, response.Error.Message)
+		}
+		return nil, fmt.Errorf("unknown error from CICD service, status %s", resp.Status)
+	}
+
+	return response, nil
+}
+
+// dispatchBuild runs CICD process if the installation is active and the repository is enabled, see startBuild
+func (h *Handler) dispatchBuild(ctx context.Context, hookID string, req *cicd.BuildRequest, dispatch *models.Dispatch) error {
+	if dispatch.TargetUsername == "" {
+		dispatch.TargetUsername = req.Username
+		dispatch.TargetRepository = req.Repository
+	}
+
+	allowed, err := h.buildAllowed(ctx, dispatch.InstallationID, dispatch.TargetUsername, dispatch.TargetRepository)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		cicdDispatches.Inc(req.Task, "skipped")
+		return nil
+	}
+
+	return h.startBuild(ctx, hookID, req, dispatch)
+}
+
+// buildAllowed checks if builds of the repository may be run: its installation is active
+// and the repository is enabled for the installation
+func (h *Handler) buildAllowed(ctx context.Context, installationID int, username, repository string) (bool, error) {
+	active, err := h.installationActive(ctx, installationID, username)
+	if err != nil {
+		return false, fmt.Errorf("cannot check installation of %s: %s", username, err)
+	}
+	if !active {
+		h.log(ctx).Warnf("Don't run ci/cd process for hook - installation of %s is not active", username)
+		return false, nil
+	}
+
+	enabled, err := h.repositoryEnabled(ctx, installationID, username, repository)
+	if err != nil {
+		return false, fmt.Errorf("cannot check repository %s/%s: %s", username, repository, err)
+	}
+	if !enabled {
+		h.log(ctx).Warnf("Don't run ci/cd process for hook - repository %s/%s is not enabled for the installation",
+			username, repository)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// startBuild runs CICD process and records the build request, the build must be allowed by buildAllowed.
+// Statuses reported by callbacks of the build are posted to dispatch.TargetUsername/dispatch.TargetRepository
+// (the built repository by default).
+func (h *Handler) startBuild(ctx context.Context, hookID string, req *cicd.BuildRequest, dispatch *models.Dispatch) (err error) {
+	ctx, span := tracing.Start(ctx, "dispatch build", tracing.KindClient)
+	span.SetAttribute("cicd.task", req.Task)
+	span.SetAttribute("github.repository", req.Username+"/"+req.Repository)
+	span.SetAttribute("github.commit", req.CommitHash)
+	defer func() {
+		span.SetError(err)
+		span.End()
+	}()
+
+	if dispatch.TargetUsername == "" {
+		dispatch.TargetUsername = req.Username
+		dispatch.TargetRepository = req.Repository
+	}
+
+	resp, err := h.runBuild(ctx, req)
+	if err != nil {
+		cicdDispatches.Inc(req.Task, "error")
+		return fmt.Errorf("cannot run ci/cd process for hook (ID %s): %s", hookID, err)
+	}
+
+	cicdDispatches.Inc(req.Task, "started")
+
+	dispatch.DeliveryID = hookID
+	dispatch.Task = req.Task
+	dispatch.Username = req.Username
+	dispatch.Repository = req.Repository
+	dispatch.Commit = req.CommitHash
+	if req.Version != nil {
+		dispatch.Version = *req.Version
+	}
+	if resp.Data != nil {
+		dispatch.RequestID = resp.Data.RequestID
+	}
+	span.SetAttribute("cicd.request_id", dispatch.RequestID)
+
+	// callbacks of the build are correlated to this trace by the build request
+	dispatch.TraceParent = span.Context.TraceParent()
+
+	ctx = h.withLog(ctx, "request_id", dispatch.RequestID)
+	h.log(ctx).Infof("ci/cd %s process for %s/%s (commit %s) is started",
+		dispatch.Task, dispatch.Username, dispatch.Repository, dispatch.Commit)
+
+	// the build is already running, so the hook shouldn't be retried because of DB failure
+	err = h.db(ctx).Save(dispatch)
+	if err != nil {
+		h.log(ctx).Errorf("cannot save build dispatch for hook: %s", err)
+	}
+
+	return nil
+}
+
+// findDispatch gets the latest build request for the commit, it returns nil if the build is unknown
+func (h *Handler) findDispatch(ctx context.Context, username, repository, commit string) (*models.Dispatch, error) {
+	st, err := h.db(ctx).SelectOneFrom(models.DispatchTable,
+		"WHERE username = $1 AND repository = $2 AND commit = $3 ORDER BY id DESC LIMIT 1",
+		username, repository, commit)
+	if err == reform.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return st.(*models.Dispatch), nil
+}
+
+// statusTarget redirects build callback to the repository where the build was requested,
+// e.g. statuses of pull requests from forks are posted on the head commit in the base repository.
+// Installation ID which has requested the build is returned, it's 0 if the build is unknown.
+// The returned context continues the trace of the hook which has requested the build.
+func (h *Handler) statusTarget(ctx context.Context, build *github.BuildCallback) (context.Context, *github.BuildCallback, int, error) {
+	dispatch, err := h.findDispatch(ctx, build.Username, build.Repository, build.CommitHash)
+	if err != nil || dispatch == nil {
+		return ctx, build, 0, err
+	}
+
+	ctx = continueTrace(ctx, dispatch)
+
+	target := *build
+	target.Username = dispatch.TargetUsername
+	target.Repository = dispatch.TargetRepository
+	if dispatch.Context != "" {
+		target.Context = &dispatch.Context
+	}
+
+	return ctx, &target, dispatch.InstallationID, nil
+}
+
+// continueTrace links the current span to the build request and returns the context
+// where following spans belong to the trace of the hook which has requested the build
+func continueTrace(ctx context.Context, dispatch *models.Dispatch) context.Context {
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttribute("cicd.request_id", dispatch.RequestID)
+
+	origin, ok := tracing.ParseTraceParent(dispatch.TraceParent)
+	if !ok {
+		return ctx
+	}
+	span.SetAttribute("origin.trace_id", origin.TraceID.String())
+	span.SetAttribute("origin.span_id", origin.SpanID.String())
+
+	// following spans of the callback (DB queries, GitHub API calls) become children of the dispatch span
+	return tracing.ContextWithRemote(ctx, origin)
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k8s-community/github-integration/config"
+	"github.com/k8s-community/github-integration/github"
+	"github.com/k8s-community/github-integration/logging"
+	"github.com/k8s-community/github-integration/secrets"
+)
+
+// fakeGitHub is GitHub API of a single repository, it keeps files, check runs and commit statuses.
+// Installations of the app are listed too.
+type fakeGitHub struct {
+	*httptest.Server
+
+	mu sync.Mutex
+
+	// files are contents of the repository by path
+	files map[string]string
+	// checksForbidden rejects Checks API requests like for an installation without Checks permission
+	checksForbidden bool
+	// installations are installations of the app, repoInstallations are their repositories by full name
+	installations     []*github.Installation
+	repoInstallations map[string]*github.Installation
+
+	checkRuns []*github.CheckRun
+	statuses  []github.CommitStatus
+}
+
+func newFakeGitHub(t *testing.T) *fakeGitHub {
+	gh := &fakeGitHub{files: make(map[string]string), repoInstallations: make(map[string]*github.Installation)}
+	gh.Server = httptest.NewServer(http.HandlerFunc(gh.serve))
+	t.Cleanup(gh.Close)
+
+	return gh
+}
+
+func (gh *fakeGitHub) serve(w http.ResponseWriter, r *http.Request) {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	path := r.URL.Path
+	reply := func(code int, v interface{}) {
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(v)
+	}
+	notFound := map[string]string{"message": "Not Found"}
+
+	if strings.Contains(path, "check-runs") && gh.checksForbidden {
+		reply(http.StatusForbidden, map[string]string{"message": "Resource not accessible by integration"})
+		return
+	}
+
+	switch {
+	case r.Method == "POST" && strings.HasSuffix(path, "/access_tokens"):
+		reply(http.StatusCreated, map[string]interface{}{"token": "token", "expires_at": time.Now().Add(time.Hour)})
+
+	case r.Method == "GET" && path == "/app/installations":
+		list := []*github.Installation{}
+		if r.URL.Query().Get("page") == "1" {
+			list = append(list, gh.installations...)
+		}
+		reply(http.StatusOK, list)
+
+	case r.Method == "GET" && strings.HasPrefix(path, "/repos/") && strings.HasSuffix(path, "/installation"):
+		inst, ok := gh.repoInstallations[strings.TrimSuffix(strings.TrimPrefix(path, "/repos/"), "/installation")]
+		if !ok {
+			reply(http.StatusNotFound, notFound)
+			return
+		}
+		reply(http.StatusOK, inst)
+
+	case r.Method == "GET" && strings.Contains(path, "/contents/"):
+		content, ok := gh.files[path[strings.Index(path, "/contents/")+len("/contents/"):]]
+		if !ok {
+			reply(http.StatusNotFound, notFound)
+			return
+		}
+		reply(http.StatusOK, map[string]string{
+			"type": "file", "encoding": "base64", "content": base64.StdEncoding.EncodeToString([]byte(content)),
+		})
+
+	case r.Method == "GET" && strings.HasSuffix(path, "/check-runs"):
+		sha := strings.Split(path, "/")[5]
+		list := map[string]interface{}{"check_runs": []*github.CheckRun{}}
+		for _, run := range gh.checkRuns {
+			if run.HeadSHA == sha && run.Name == r.URL.Query().Get("check_name") {
+				list["check_runs"] = []*github.CheckRun{run}
+			}
+		}
+		reply(http.StatusOK, list)
+
+	case r.Method == "POST" && strings.HasSuffix(path, "/check-runs"):
+		run := new(github.CheckRun)
+		json.NewDecoder(r.Body).Decode(run)
+		run.ID = int64(len(gh.checkRuns) + 1)
+		gh.checkRuns = append(gh.checkRuns, run)
+		reply(http.StatusCreated, run)
+
+	case r.Method == "PATCH" && strings.Contains(path, "/check-runs/"):
+		id, _ := strconv.Atoi(path[strings.LastIndex(path, "/")+1:])
+		if id < 1 || id > len(gh.checkRuns) {
+			reply(http.StatusNotFound, notFound)
+			return
+		}
+		// fields which are sent replace the previous ones, the output is replaced as a whole
+		patch := new(github.CheckRun)
+		json.NewDecoder(r.Body).Decode(patch)
+		run := gh.checkRuns[id-1]
+		if patch.Status != "" {
+			run.Status = patch.Status
+		}
+		if patch.Conclusion != nil {
+			run.Conclusion = patch.Conclusion
+		}
+		if patch.DetailsURL != nil {
+			run.DetailsURL = patch.DetailsURL
+		}
+		if patch.Output != nil {
+			run.Output = patch.Output
+		}
+		reply(http.StatusOK, run)
+
+	case r.Method == "POST" && strings.Contains(path, "/statuses/"):
+		status := github.CommitStatus{}
+		json.NewDecoder(r.Body).Decode(&status)
+		gh.statuses = append(gh.statuses, status)
+		reply(http.StatusCreated, status)
+
+	default:
+		reply(http.StatusNotFound, notFound)
+	}
+}
+
+// checkRun returns the check run with the name, it's nil if there is no one
+func (gh *fakeGitHub) checkRun(name string) *github.CheckRun {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	for _, run := range gh.checkRuns {
+		if run.Name == name {
+			return run
+		}
+	}
+
+	return nil
+}
+
+// status returns the latest state of the commit status context, it's empty if there is no one
+func (gh *fakeGitHub) status(context string) string {
+	gh.mu.Lock()
+	defer gh.mu.Unlock()
+
+	state := ""
+	for _, status := range gh.statuses {
+		if status.Context != nil && *status.Context == context {
+			state = status.State
+		}
+	}
+
+	return state
+}
+
+var (
+	testKeyOnce sync.Once
+	testKey     []byte
+)
+
+// testHandler creates a handler which calls the GitHub API and reports builds as the reporter defines
+func testHandler(t *testing.T, apiURL, reporter string) *Handler {
+	testKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		testKey = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	})
+
+	privateKey, err := secrets.New("GITHUBINT_PRIV_KEY", string(testKey), "", github.ValidatePrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, err := logging.New(ioutil.Discard, logging.LevelError, logging.FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Handler{
+		Log: log,
+		Config: &config.Config{
+			IntegrationID: 1,
+			APIURL:        apiURL,
+			Reporter:      reporter,
+		},
+		PrivateKey: privateKey,
+	}
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/k8s-community/github-integration/models"
+	"github.com/k8s-community/github-integration/tracing"
+	"github.com/lib/pq"
+	"gopkg.in/reform.v1"
+	githubhook "gopkg.in/rjz/githubhook.v0"
+)
+
+const (
+	// inboxPollInterval is how often idle workers look for deliveries which are due for retry
+	inboxPollInterval = 5 * time.Second
+
+	// inboxLease is how long a claimed delivery stays invisible for other workers,
+	// it is picked up again if the worker (or the whole service) dies during processing
+	inboxLease = 5 * time.Minute
+
+	// inboxProcessTimeout bounds processing of a delivery, it's shorter than the lease,
+	// so the delivery isn't claimed by another worker while it's being processed
+	inboxProcessTimeout = 4 * time.Minute
+
+	// inboxRetryBase and inboxRetryMax bound exponential backoff between attempts
+	inboxRetryBase = 10 * time.Second
+	inboxRetryMax  = 10 * time.Minute
+
+	// inboxPurgeInterval is how often finished deliveries older than retention window are removed
+	inboxPurgeInterval = time.Hour
+)
+
+// Outcomes of processing attempts
+const (
+	outcomeDone   = "done"
+	outcomeFailed = "failed"
+	outcomeRetry  = "retry"
+)
+
+// claimQuery marks the oldest due delivery as processing and returns its ID.
+// SKIP LOCKED allows several workers (and several replicas) to drain the inbox concurrently.
+const claimQuery = `UPDATE webhook_deliveries
+SET status = $1, attempts = attempts + 1, next_attempt_at = $2, updated_at = $3
+WHERE id = (
+	SELECT id FROM webhook_deliveries
+	WHERE status IN ($1, $4) AND next_attempt_at <= $3
+	ORDER BY next_attempt_at, id
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id`
+
+// Inbox is a durable queue of web hooks: deliveries are stored in DB by WebHookHandler
+// and processed asynchronously by a pool of workers
+type Inbox struct {
+	h           *Handler
+	workers     int
+	maxAttempts int
+
+	// retention is how long processed deliveries are kept to recognize duplicates
+	retention time.Duration
+
+	wakeup   chan struct{}
+	quit     chan struct{}
+	quitOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewInbox creates an Inbox which processes deliveries with the given handler
+func NewInbox(h *Handler, workers int, maxAttempts int, retention time.Duration) *Inbox {
+	return &Inbox{
+		h:           h,
+		workers:     workers,
+		maxAttempts: maxAttempts,
+		retention:   retention,
+		wakeup:      make(chan struct{}, workers),
+		quit:        make(chan struct{}),
+	}
+}
+
+// Start runs workers in background
+func (in *Inbox) Start() {
+	for i := 0; i < in.workers; i++ {
+		in.wg.Add(1)
+		go in.work()
+	}
+
+	in.wg.Add(1)
+	go in.purge()
+}
+
+// Shutdown asks workers to quit and waits until deliveries which are in progress are finished
+// or the context is done. Deliveries which aren't finished are processed again after the lease expires.
+// It may be called more than once.
+func (in *Inbox) Shutdown(ctx context.Context) error {
+	in.quitOnce.Do(func() { close(in.quit) })
+
+	done := make(chan struct{})
+	go func() {
+		in.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify wakes up an idle worker to process a new delivery without waiting for the next poll
+func (in *Inbox) Notify() {
+	select {
+	case in.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Enqueue stores verified hook in the inbox. If the hook duplicates a delivery received within
+// the retention window (the same delivery ID or the same natural key), the prior delivery is returned
+// and created is false. Failed duplicates are scheduled again, so "Redeliver" retries them.
+// A delivery which is enqueued concurrently by another request is a duplicate too.
+func (in *Inbox) Enqueue(ctx context.Context, hook *githubhook.Hook) (delivery *models.WebhookDelivery, created bool, err error) {
+	naturalKey, err := hookNaturalKey(hook)
+	if err != nil {
+		return nil, false, err
+	}
+
+	delivery, err = in.duplicate(ctx, hook.Id, naturalKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if delivery != nil {
+		if delivery.Status == models.DeliveryFailed {
+			delivery.Status = models.DeliveryPending
+			delivery.Attempts = 0
+			delivery.NextAttemptAt = time.Now().UTC()
+			err = in.h.db(ctx).Update(delivery)
+			if err != nil {
+				return nil, false, err
+			}
+			in.Notify()
+		}
+
+		return delivery, false, nil
+	}
+
+	delivery = &models.WebhookDelivery{
+		DeliveryID: hook.Id,
+		Event:      hook.Event,
+		NaturalKey: naturalKey,
+		Payload:    string(hook.Payload),
+		Status:     models.DeliveryPending,
+
+		// workers continue the trace of the web hook request
+		TraceParent: tracing.SpanContextFromContext(ctx).TraceParent(),
+	}
+
+	err = in.h.db(ctx).Insert(delivery)
+	if isUniqueViolation(err) {
+		// simultaneous redelivery has passed the check above and has been inserted first
+		prior, dupErr := in.duplicate(ctx, hook.Id, naturalKey)
+		if dupErr != nil {
+			return nil, false, dupErr
+		}
+		if prior != nil {
+			return prior, false, nil
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	in.Notify()
+
+	return delivery, true, nil
+}
+
+// duplicate looks for a delivery with the same delivery ID or natural key, it returns nil if there is no one
+func (in *Inbox) duplicate(ctx context.Context, deliveryID, naturalKey string) (*models.WebhookDelivery, error) {
+	st, err := in.h.db(ctx).FindOneFrom(models.WebhookDeliveryTable, "delivery_id", deliveryID)
+	if err == nil {
+		return st.(*models.WebhookDelivery), nil
+	}
+	if err != reform.ErrNoRows {
+		return nil, err
+	}
+
+	if naturalKey == "" {
+		return nil, nil
+	}
+
+	since := time.Now().UTC().Add(-in.retention)
+	st, err = in.h.db(ctx).SelectOneFrom(models.WebhookDeliveryTable,
+		"WHERE natural_key = $1 AND created_at >= $2 ORDER BY id DESC LIMIT 1", naturalKey, since)
+	if err == reform.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return st.(*models.WebhookDelivery), nil
+}
+
+func (in *Inbox) work() {
+	defer in.wg.Done()
+
+	for {
+		select {
+		case <-in.quit:
+			return
+		default:
+		}
+
+		delivery, err := in.claim(time.Now().UTC())
+		if err != nil {
+			in.h.Log.Errorf("cannot claim web hook delivery: %s", err)
+		}
+
+		if delivery == nil {
+			select {
+			case <-in.quit:
+				return
+			case <-in.wakeup:
+			case <-time.After(inboxPollInterval):
+			}
+			continue
+		}
+
+		in.process(delivery)
+	}
+}
+
+// claim locks the next due delivery for the current worker until the lease expires,
+// it returns nil if there is nothing to do
+func (in *Inbox) claim(now time.Time) (*models.WebhookDelivery, error) {
+	var id int64
+	err := in.h.DB.QueryRow(claimQuery, models.DeliveryProcessing, now.Add(inboxLease), now, models.DeliveryPending).Scan(&id)
+	if err == reform.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := in.h.DB.FindByPrimaryKeyFrom(models.WebhookDeliveryTable, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return record.(*models.WebhookDelivery), nil
+}
+
+// process runs the hook and stores the outcome, failed deliveries are rescheduled with backoff
+func (in *Inbox) process(delivery *models.WebhookDelivery) {
+	hook := &githubhook.Hook{
+		Id:      delivery.DeliveryID,
+		Event:   delivery.Event,
+		Payload: []byte(delivery.Payload),
+	}
+
+	action := hookAction(hook.Payload)
+
+	ctx := context.Background()
+	if sc, ok := tracing.ParseTraceParent(delivery.TraceParent); ok {
+		ctx = tracing.ContextWithRemote(ctx, sc)
+	}
+	ctx = in.h.withLog(ctx, "delivery", hook.Id, "event", hook.Event, "attempt", delivery.Attempts)
+	ctx, span := tracing.Start(ctx, "process "+hook.Event, tracing.KindInternal)
+	span.SetAttribute("github.delivery", hook.Id)
+	span.SetAttribute("github.event", hook.Event)
+	span.SetAttribute("github.action", action)
+	span.SetAttribute("inbox.attempt", delivery.Attempts)
+	defer span.End()
+
+	runCtx, cancel := context.WithTimeout(ctx, inboxProcessTimeout)
+	err := in.run(runCtx, hook)
+	cancel()
+	span.SetError(err)
+
+	now := time.Now().UTC()
+	outcome := in.complete(delivery, err, now)
+	webhookProcessed.Inc(hook.Event, action, outcome)
+
+	switch outcome {
+	case outcomeDone:
+		in.h.log(ctx).Infof("finished to process hook")
+	case outcomeFailed:
+		in.h.log(ctx).Errorf("cannot process hook, giving up after %d attempts: %s", delivery.Attempts, err)
+	default:
+		in.h.log(ctx).Warnf("cannot process hook, retry in %s: %s", delivery.NextAttemptAt.Sub(now), err)
+	}
+
+	saved, err := in.save(ctx, delivery)
+	if err != nil {
+		in.h.log(ctx).Errorf("cannot save state of hook: %s", err)
+	} else if !saved {
+		in.h.log(ctx).Warnf("state of hook isn't saved, the lease has expired and the delivery has been claimed again")
+	}
+}
+
+// save stores the outcome of the attempt if the worker still holds the lease: the delivery
+// isn't claimed again since it has been claimed for this attempt. It returns false if the lease is lost.
+func (in *Inbox) save(ctx context.Context, delivery *models.WebhookDelivery) (bool, error) {
+	delivery.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+
+	res, err := in.h.db(ctx).Exec(`UPDATE webhook_deliveries
+SET status = $1, last_error = $2, next_attempt_at = $3, updated_at = $4
+WHERE id = $5 AND status = $6 AND attempts = $7`,
+		delivery.Status, delivery.LastError, delivery.NextAttemptAt, delivery.UpdatedAt,
+		delivery.ID, models.DeliveryProcessing, delivery.Attempts)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// complete stores the result of the attempt in the delivery: it's done, scheduled for retry with backoff
+// or failed when attempts are exhausted. It returns the outcome for metrics.
+func (in *Inbox) complete(delivery *models.WebhookDelivery, err error, now time.Time) string {
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDone
+		delivery.LastError = ""
+		return outcomeDone
+
+	case delivery.Attempts >= in.maxAttempts:
+		delivery.Status = models.DeliveryFailed
+		delivery.LastError = err.Error()
+		return outcomeFailed
+
+	default:
+		delivery.Status = models.DeliveryPending
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(retryBackoff(delivery.Attempts))
+		return outcomeRetry
+	}
+}
+
+// run processes the hook and converts a panic into an error, so the delivery is retried
+func (in *Inbox) run(ctx context.Context, hook *githubhook.Hook) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return in.h.processHook(ctx, hook)
+}
+
+// purge periodically removes finished deliveries which are older than the retention window
+func (in *Inbox) purge() {
+	defer in.wg.Done()
+
+	for {
+		before := time.Now().UTC().Add(-in.retention)
+		count, err := in.h.DB.DeleteFrom(models.WebhookDeliveryTable,
+			"WHERE status IN ($1, $2) AND updated_at < $3", models.DeliveryDone, models.DeliveryFailed, before)
+		if err != nil {
+			in.h.Log.Errorf("cannot purge web hook deliveries: %s", err)
+		} else if count > 0 {
+			in.h.Log.Infof("purged %d web hook deliveries older than %s", count, in.retention)
+		}
+
+		select {
+		case <-in.quit:
+			return
+		case <-time.After(inboxPurgeInterval):
+		}
+	}
+}
+
+// retryBackoff returns delay before the next attempt, it doubles with every attempt
+func retryBackoff(attempt int) time.Duration {
+	delay := inboxRetryBase
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= inboxRetryMax {
+			return inboxRetryMax
+		}
+	}
+
+	return delay
+}
+
+// isUniqueViolation checks if the error is a violation of a unique constraint in Postgres
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation"
+}
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/k8s-community/github-integration/logging"
+	"github.com/k8s-community/github-integration/models"
+	"github.com/lib/pq"
+	"gopkg.in/reform.v1"
+	"gopkg.in/reform.v1/dialects/postgresql"
+	githubhook "gopkg.in/rjz/githubhook.v0"
+)
+
+// testDatabaseEnv is the URL of Postgres DB for DB tests, they are skipped if it isn't set.
+// Every test creates its own schema, so the DB may be shared.
+const testDatabaseEnv = "GITHUBINT_TEST_DATABASE_URL"
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{6, 320 * time.Second},
+		{7, inboxRetryMax},
+		{100, inboxRetryMax},
+	}
+
+	for _, test := range tests {
+		if delay := retryBackoff(test.attempt); delay != test.delay {
+			t.Errorf("attempt %d: expected %s, got %s", test.attempt, test.delay, delay)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	in := &Inbox{maxAttempts: 3}
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	failure := errors.New("cicd is unavailable")
+
+	tests := []struct {
+		attempts int
+		err      error
+		outcome  string
+		status   string
+		next     time.Time
+	}{
+		{1, nil, outcomeDone, models.DeliveryDone, time.Time{}},
+		{3, nil, outcomeDone, models.DeliveryDone, time.Time{}},
+		{1, failure, outcomeRetry, models.DeliveryPending, now.Add(10 * time.Second)},
+		{2, failure, outcomeRetry, models.DeliveryPending, now.Add(20 * time.Second)},
+		{3, failure, outcomeFailed, models.DeliveryFailed, time.Time{}},
+	}
+
+	for _, test := range tests {
+		delivery := &models.WebhookDelivery{Status: models.DeliveryProcessing, Attempts: test.attempts, LastError: "previous"}
+
+		outcome := in.complete(delivery, test.err, now)
+		if outcome != test.outcome || delivery.Status != test.status {
+			t.Errorf("attempt %d, error %v: expected %s (%s), got %s (%s)",
+				test.attempts, test.err, test.outcome, test.status, outcome, delivery.Status)
+		}
+		if !test.next.IsZero() && !delivery.NextAttemptAt.Equal(test.next) {
+			t.Errorf("attempt %d: expected next attempt at %s, got %s", test.attempts, test.next, delivery.NextAttemptAt)
+		}
+
+		lastError := ""
+		if test.err != nil {
+			lastError = test.err.Error()
+		}
+		if delivery.LastError != lastError {
+			t.Errorf("attempt %d: expected last error %q, got %q", test.attempts, lastError, delivery.LastError)
+		}
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	var unique error = &pq.Error{Code: "23505"}
+
+	tests := []struct {
+		err    error
+		unique bool
+	}{
+		{nil, false},
+		{errors.New("duplicate key value violates unique constraint"), false},
+		{&pq.Error{Code: "23503"}, false},
+		{unique, true},
+		{fmt.Errorf("insert: %w", unique), true},
+	}
+
+	for _, test := range tests {
+		if got := isUniqueViolation(test.err); got != test.unique {
+			t.Errorf("%v: expected %t, got %t", test.err, test.unique, got)
+		}
+	}
+}
+
+func TestInboxEnqueueConcurrently(t *testing.T) {
+	in := testInbox(t)
+	hook := &githubhook.Hook{Id: "delivery-1", Event: "ping", Payload: []byte(`{}`)}
+
+	const requests = 10
+
+	var (
+		mu      sync.Mutex
+		created int
+		ids     = make(map[int64]bool)
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			delivery, ok, err := in.Enqueue(context.Background(), hook)
+			if err != nil {
+				t.Errorf("redelivery must be accepted, got %s", err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				created++
+			}
+			ids[delivery.ID] = true
+		}()
+	}
+	wg.Wait()
+
+	if created != 1 || len(ids) != 1 {
+		t.Errorf("expected one delivery, created %d, got IDs %v", created, ids)
+	}
+}
+
+func TestInboxEnqueueFailed(t *testing.T) {
+	in := testInbox(t)
+	ctx := context.Background()
+	hook := &githubhook.Hook{Id: "delivery-1", Event: "ping", Payload: []byte(`{}`)}
+
+	delivery, _, err := in.Enqueue(ctx, hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery.Status = models.DeliveryFailed
+	delivery.Attempts = in.maxAttempts
+	delivery.NextAttemptAt = time.Now().UTC().Add(time.Hour)
+	if err = in.h.DB.Update(delivery); err != nil {
+		t.Fatal(err)
+	}
+
+	// "Redeliver" of the failed delivery schedules it again
+	again, created, err := in.Enqueue(ctx, hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || again.ID != delivery.ID {
+		t.Fatalf("expected duplicate of %d, got %d (created %t)", delivery.ID, again.ID, created)
+	}
+
+	claimed, err := in.claim(time.Now().UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed == nil || claimed.ID != delivery.ID || claimed.Attempts != 1 {
+		t.Errorf("failed delivery must be retried from the first attempt, got %+v", claimed)
+	}
+}
+
+func TestInboxClaimLease(t *testing.T) {
+	in := testInbox(t)
+	ctx := context.Background()
+
+	first, _, err := in.Enqueue(ctx, &githubhook.Hook{Id: "delivery-1", Event: "ping", Payload: []byte(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := in.Enqueue(ctx, &githubhook.Hook{Id: "delivery-2", Event: "ping", Payload: []byte(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	claim := func(at time.Time) *models.WebhookDelivery {
+		t.Helper()
+		delivery, err := in.claim(at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return delivery
+	}
+
+	// deliveries are claimed in order, claimed ones are invisible for other workers
+	if d := claim(now); d == nil || d.ID != first.ID || d.Status != models.DeliveryProcessing || d.Attempts != 1 {
+		t.Fatalf("expected the first delivery on the first attempt, got %+v", d)
+	}
+	if d := claim(now); d == nil || d.ID != second.ID {
+		t.Fatalf("expected the second delivery, got %+v", d)
+	}
+	if d := claim(now.Add(inboxLease - time.Minute)); d != nil {
+		t.Fatalf("leased deliveries must not be claimed, got %+v", d)
+	}
+
+	// the worker which has claimed the first delivery died, it's claimed again after the lease expires
+	expired := now.Add(inboxLease + time.Minute)
+	retried := claim(expired)
+	if retried == nil || retried.ID != first.ID || retried.Attempts != 2 {
+		t.Fatalf("expected the first delivery on the second attempt, got %+v", retried)
+	}
+
+	// failed attempt is retried after backoff
+	in.complete(retried, errors.New("cicd is unavailable"), expired)
+	if err = in.h.DB.Update(retried); err != nil {
+		t.Fatal(err)
+	}
+	if d := claim(expired); d == nil || d.ID != second.ID {
+		t.Fatalf("expected the second delivery with expired lease, got %+v", d)
+	}
+	if d := claim(retried.NextAttemptAt.Add(-time.Second)); d != nil {
+		t.Fatalf("delivery must not be retried before backoff, got %+v", d)
+	}
+	if d := claim(retried.NextAttemptAt); d == nil || d.ID != first.ID || d.Attempts != 3 {
+		t.Fatalf("expected the first delivery on the third attempt, got %+v", d)
+	}
+
+	// finished deliveries aren't claimed anymore
+	for _, d := range []*models.WebhookDelivery{first, second} {
+		d.Status = models.DeliveryDone
+		if err = in.h.DB.Update(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := claim(expired.Add(time.Hour)); d != nil {
+		t.Errorf("finished deliveries must not be claimed, got %+v", d)
+	}
+}
+
+func TestInboxSaveLease(t *testing.T) {
+	in := testInbox(t)
+
+	delivery, _, err := in.Enqueue(context.Background(), &githubhook.Hook{Id: "delivery-1", Event: "ping", Payload: []byte(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	stale, err := in.claim(now)
+	if err != nil || stale == nil || stale.ID != delivery.ID {
+		t.Fatalf("expected the delivery, got %+v, %v", stale, err)
+	}
+
+	// the first worker hangs, the lease expires and the delivery is claimed by another worker
+	current, err := in.claim(now.Add(inboxLease + time.Minute))
+	if err != nil || current == nil || current.ID != delivery.ID {
+		t.Fatalf("expected the delivery with expired lease, got %+v, %v", current, err)
+	}
+
+	in.complete(stale, nil, now)
+	saved, err := in.save(context.Background(), stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved {
+		t.Error("outcome of the worker which has lost the lease must not be saved")
+	}
+
+	in.complete(current, errors.New("cicd is unavailable"), now)
+	saved, err = in.save(context.Background(), current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved {
+		t.Fatal("outcome of the lease holder must be saved")
+	}
+
+	record, err := in.h.DB.FindByPrimaryKeyFrom(models.WebhookDeliveryTable, delivery.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := record.(*models.WebhookDelivery); d.Status != models.DeliveryPending || d.Attempts != 2 || d.LastError == "" {
+		t.Errorf("expected the retry of the second attempt, got %+v", d)
+	}
+}
+
+func TestInboxProcessTimeout(t *testing.T) {
+	if inboxProcessTimeout >= inboxLease {
+		t.Errorf("processing timeout %s must be shorter than the lease %s", inboxProcessTimeout, inboxLease)
+	}
+}
+
+// testInbox creates an inbox on a new schema of the test DB with all migrations applied
+func testInbox(t *testing.T) *Inbox {
+	log, err := logging.New(ioutil.Discard, logging.LevelError, logging.FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{DB: testDB(t), Log: log}
+	return NewInbox(h, 1, 3, time.Hour)
+}
+
+// testDB creates a schema with applied migrations in the DB of testDatabaseEnv, the test is skipped if it isn't set
+func testDB(t *testing.T) *reform.DB {
+	dsn := os.Getenv(testDatabaseEnv)
+	if dsn == "" {
+		t.Skipf("%s isn't set", testDatabaseEnv)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err = admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		admin, err := sql.Open("postgres", dsn)
+		if err == nil {
+			admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+			admin.Close()
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unknown parameters are passed to the server as run-time parameters
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	conn, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	migrations, err := filepath.Glob(filepath.Join("..", "migrations", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(migrations)
+	for _, file := range migrations {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = conn.Exec(string(data)); err != nil {
+			t.Fatalf("%s: %s", file, err)
+		}
+	}
+
+	return reform.NewDB(conn, postgresql.Dialect, nil)
+}
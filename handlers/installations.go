@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/k8s-community/github-integration/models"
+	"gopkg.in/reform.v1"
+)
+
+// errInstallationInactive is returned when the installation is suspended or deleted
+var errInstallationInactive = errors.New("installation is not active")
+
+// account is a GitHub user or organization which the integration is installed for, unknown fields are empty
+type account struct {
+	ID    int
+	Type  string
+	Login string
+}
+
+// accountOf returns the account of GitHub user or organization
+func accountOf(u *github.User) account {
+	if u == nil {
+		return account{}
+	}
+
+	return account{ID: u.GetID(), Type: u.GetType(), Login: u.GetLogin()}
+}
+
+// findInstallation gets installation by account ID, installation ID or login in that order, zero values are skipped.
+// Login is the last resort: it changes when the account is renamed and may be taken by another account.
+func (h *Handler) findInstallation(ctx context.Context, accountID, instID int, login string) (*models.Installation, error) {
+	if accountID != 0 {
+		st, err := h.db(ctx).FindOneFrom(models.InstallationTable, "account_id", accountID)
+		if err == nil {
+			return st.(*models.Installation), nil
+		}
+		if err != reform.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	if instID != 0 {
+		st, err := h.db(ctx).FindOneFrom(models.InstallationTable, "installation_id", instID)
+		if err == nil {
+			return st.(*models.Installation), nil
+		}
+		if err != reform.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	if login != "" {
+		st, err := h.db(ctx).SelectOneFrom(models.InstallationTable, "WHERE username = $1 ORDER BY id DESC LIMIT 1", login)
+		if err != nil {
+			return nil, err
+		}
+
+		inst := st.(*models.Installation)
+		if accountID != 0 && inst.AccountID != 0 && inst.AccountID != accountID {
+			// the login belonged to another account
+			return nil, reform.ErrNoRows
+		}
+
+		return inst, nil
+	}
+
+	return nil, reform.ErrNoRows
+}
+
+// updateInstallation saves installation of the account with the given state, empty state keeps the current one.
+// Timestamps of suspension and deletion are kept until the installation is activated again.
+// The login is updated if the account is renamed.
+func (h *Handler) updateInstallation(ctx context.Context, acc account, instID int, status string) error {
+	inst, err := h.findInstallation(ctx, acc.ID, instID, acc.Login)
+	if err == reform.ErrNoRows {
+		inst, err = &models.Installation{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	login := inst.Username
+
+	if acc.ID != 0 {
+		inst.AccountID = acc.ID
+	}
+	if acc.Type != "" {
+		inst.AccountType = acc.Type
+	}
+	if acc.Login != "" {
+		inst.Username = acc.Login
+	}
+	inst.InstallationID = instID
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	switch status {
+	case models.InstallationActive:
+		inst.SuspendedAt = nil
+		inst.DeletedAt = nil
+	case models.InstallationSuspended:
+		inst.SuspendedAt = &now
+	case models.InstallationDeleted:
+		inst.DeletedAt = &now
+	}
+	if status != "" {
+		inst.Status = status
+	}
+
+	err = h.db(ctx).Save(inst)
+	if err != nil {
+		return fmt.Errorf("couldn't save installation for %s: %s", inst.Username, err)
+	}
+
+	if login != "" && login != inst.Username {
+		h.log(ctx).Infof("account %d is renamed from %s to %s", inst.AccountID, login, inst.Username)
+
+		_, err = h.db(ctx).Exec("UPDATE installation_repositories SET username = $1 WHERE installation_id = $2 AND username = $3",
+			inst.Username, inst.InstallationID, login)
+		if err != nil {
+			return fmt.Errorf("couldn't rename repositories of %s: %s", login, err)
+		}
+	}
+
+	return nil
+}
+
+// setInstallationID saves installation of the account which has sent an event
+func (h *Handler) setInstallationID(ctx context.Context, acc account, instID int) {
+	err := h.updateInstallation(ctx, acc, instID, "")
+	if err != nil {
+		h.log(ctx).Errorf("Couldn't save installation: %s", err)
+	}
+}
+
+// installation gets active installation of the repository, errInstallationInactive is returned for suspended
+// or deleted installation. If the installation isn't saved (e.g. after DB loss), it's resolved via GitHub API.
+func (h *Handler) installation(ctx context.Context, instID int, owner, repo string) (*models.Installation, error) {
+	inst, err := h.findInstallation(ctx, 0, instID, owner)
+	if err == reform.ErrNoRows {
+		inst, err = h.resolveInstallation(ctx, owner, repo)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if inst.Status != models.InstallationActive {
+		return nil, fmt.Errorf("installation %d of %s is %s: %w", inst.InstallationID, inst.Username, inst.Status, errInstallationInactive)
+	}
+
+	return inst, nil
+}
+
+// installationActive checks if builds may be dispatched for the installation, unknown installations are considered active
+func (h *Handler) installationActive(ctx context.Context, instID int, login string) (bool, error) {
+	inst, err := h.findInstallation(ctx, 0, instID, login)
+	if err == reform.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return inst.Status == models.InstallationActive, nil
+}
+
+// resolveInstallation finds the installation of the repository via GitHub API and saves it
+func (h *Handler) resolveInstallation(ctx context.Context, owner, repo string) (*models.Installation, error) {
+	// integration endpoints don't need installation ID
+	client, err := h.githubClient(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't init client for github: %s", err)
+	}
+
+	found, err := client.RepositoryInstallation(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	status := models.InstallationActive
+	if found.SuspendedAt != nil {
+		status = models.InstallationSuspended
+	}
+
+	acc := account{ID: found.Account.ID, Type: found.Account.Type, Login: found.Account.Login}
+	h.log(ctx).Infof("installation %d of %s is resolved for %s/%s", found.ID, acc.Login, owner, repo)
+
+	err = h.updateInstallation(ctx, acc, found.ID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.findInstallation(ctx, acc.ID, found.ID, acc.Login)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/k8s-community/github-integration/config"
+	"github.com/k8s-community/github-integration/github"
+	"github.com/k8s-community/github-integration/models"
+	"gopkg.in/reform.v1"
+)
+
+// testInstallations creates a handler with the saved installations, GitHub API is served by the fake
+func testInstallations(t *testing.T, gh *fakeGitHub, installations ...*models.Installation) *Handler {
+	db := testDB(t)
+	for _, inst := range installations {
+		if err := db.Insert(inst); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := testHandler(t, gh.URL, config.ReporterStatuses)
+	h.DB = db
+
+	return h
+}
+
+func TestFindInstallation(t *testing.T) {
+	h := testInstallations(t, newFakeGitHub(t),
+		&models.Installation{AccountID: 1, AccountType: "User", Username: "owner", InstallationID: 10},
+		&models.Installation{AccountID: 2, AccountType: "Organization", Username: "org", InstallationID: 20},
+		// installation saved before account IDs
+		&models.Installation{Username: "legacy", InstallationID: 30},
+	)
+
+	tests := []struct {
+		accountID int
+		instID    int
+		login     string
+		expected  int
+	}{
+		// account ID goes first, then installation ID, then login
+		{1, 20, "org", 10},
+		{0, 20, "owner", 20},
+		{0, 0, "owner", 10},
+		{99, 10, "org", 10},
+		{99, 99, "org", 0},
+		// the login belongs to another account now
+		{99, 0, "owner", 0},
+		{99, 99, "legacy", 30},
+		{0, 0, "unknown", 0},
+		{0, 0, "", 0},
+	}
+
+	for _, test := range tests {
+		inst, err := h.findInstallation(context.Background(), test.accountID, test.instID, test.login)
+		if test.expected == 0 {
+			if err != reform.ErrNoRows {
+				t.Errorf("%d, %d, %q: expected no installation, got %+v, %v", test.accountID, test.instID, test.login, inst, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%d, %d, %q: %s", test.accountID, test.instID, test.login, err)
+		} else if inst.InstallationID != test.expected {
+			t.Errorf("%d, %d, %q: expected installation %d, got %d",
+				test.accountID, test.instID, test.login, test.expected, inst.InstallationID)
+		}
+	}
+}
+
+func TestUpdateInstallationRename(t *testing.T) {
+	h := testInstallations(t, newFakeGitHub(t),
+		&models.Installation{AccountID: 1, AccountType: "User", Username: "owner", InstallationID: 10})
+	ctx := context.Background()
+
+	for i, name := range []string{"repo1", "repo2"} {
+		err := h.DB.Insert(&models.InstallationRepository{InstallationID: 10, RepositoryID: i + 1, Username: "owner", Repository: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// events of the renamed account have the new login
+	err := h.updateInstallation(ctx, account{ID: 1, Type: "User", Login: "renamed"}, 10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if inst, err := h.findInstallation(ctx, 0, 0, "renamed"); err != nil || inst.InstallationID != 10 {
+		t.Errorf("installation must be found by the new login, got %+v, %v", inst, err)
+	}
+	if inst, err := h.findInstallation(ctx, 0, 0, "owner"); err != reform.ErrNoRows {
+		t.Errorf("installation must not be found by the previous login, got %+v, %v", inst, err)
+	}
+
+	sts, err := h.DB.SelectAllFrom(models.InstallationRepositoryTable, "WHERE installation_id = $1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range sts {
+		if repo := st.(*models.InstallationRepository); repo.Username != "renamed" {
+			t.Errorf("repository %s must be renamed, got %s", repo.Repository, repo.Username)
+		}
+	}
+	if len(sts) != 2 {
+		t.Errorf("expected 2 repositories, got %d", len(sts))
+	}
+}
+
+func TestResolveInstallation(t *testing.T) {
+	suspendedAt := time.Now()
+
+	gh := newFakeGitHub(t)
+	gh.repoInstallations["new/repo"] = &github.Installation{ID: 40, Account: github.Account{ID: 4, Login: "new", Type: "User"}}
+	gh.repoInstallations["suspended/repo"] = &github.Installation{
+		ID: 50, Account: github.Account{ID: 5, Login: "suspended", Type: "User"}, SuspendedAt: &suspendedAt}
+	// the account is renamed and the integration is installed again while the service was down
+	gh.repoInstallations["reinstalled/repo"] = &github.Installation{ID: 61, Account: github.Account{ID: 6, Login: "reinstalled", Type: "User"}}
+
+	h := testInstallations(t, gh,
+		&models.Installation{AccountID: 1, AccountType: "User", Username: "owner", InstallationID: 10},
+		&models.Installation{AccountID: 6, AccountType: "User", Username: "previous", InstallationID: 60,
+			Status: models.InstallationDeleted})
+
+	tests := []struct {
+		instID   int
+		owner    string
+		expected int
+		err      error
+	}{
+		// saved installations don't need GitHub API
+		{10, "owner", 10, nil},
+		{40, "new", 40, nil},
+		{50, "suspended", 0, errInstallationInactive},
+		{61, "reinstalled", 61, nil},
+		{70, "unknown", 0, github.ErrNotFound},
+	}
+
+	for _, test := range tests {
+		inst, err := h.installation(context.Background(), test.instID, test.owner, "repo")
+		if test.err != nil {
+			if !errors.Is(err, test.err) {
+				t.Errorf("%s: expected %v, got %+v, %v", test.owner, test.err, inst, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: %s", test.owner, err)
+		} else if inst.InstallationID != test.expected || inst.Username != test.owner || inst.Status != models.InstallationActive {
+			t.Errorf("%s: expected active installation %d, got %+v", test.owner, test.expected, inst)
+		}
+	}
+
+	// resolved installations are saved, the renamed account keeps its row
+	for _, test := range []struct {
+		accountID, instID int
+		status            string
+	}{
+		{4, 40, models.InstallationActive},
+		{5, 50, models.InstallationSuspended},
+		{6, 61, models.InstallationActive},
+	} {
+		inst, err := h.findInstallation(context.Background(), test.accountID, 0, "")
+		if err != nil {
+			t.Errorf("account %d: %s", test.accountID, err)
+			continue
+		}
+		if inst.InstallationID != test.instID || inst.Status != test.status {
+			t.Errorf("account %d: expected installation %d %s, got %+v", test.accountID, test.instID, test.status, inst)
+		}
+	}
+	if sts, err := h.DB.SelectAllFrom(models.InstallationTable, ""); err != nil || len(sts) != 4 {
+		t.Errorf("expected 4 installations, got %d, %v", len(sts), err)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/k8s-community/github-integration/metrics"
+	"github.com/k8s-community/github-integration/tracing"
+	"github.com/takama/router"
+)
+
+var (
+	webhookDeliveries = metrics.NewCounterVec("githubint_webhook_deliveries_total",
+		"Received web hooks by event, action and outcome (queued, duplicate, invalid, error).",
+		"event", "action", "outcome")
+
+	webhookProcessed = metrics.NewCounterVec("githubint_webhook_processed_total",
+		"Processed web hooks by event, action and outcome (done, retry, failed).",
+		"event", "action", "outcome")
+
+	cicdDispatches = metrics.NewCounterVec("githubint_cicd_dispatches_total",
+		"Build requests to CICD service by task and result (started, error, skipped).",
+		"task", "result")
+
+	commitStatusUpdates = metrics.NewCounterVec("githubint_commit_status_updates_total",
+		"Build callbacks by build state and HTTP status code of the response.",
+		"state", "code")
+
+	requestDuration = metrics.NewHistogramVec("githubint_http_request_duration_seconds",
+		"Latency of HTTP requests by handler and response status code.", nil,
+		"handler", "code")
+)
+
+// MetricsHandler exposes metrics in Prometheus text format
+func (h *Handler) MetricsHandler(c *router.Control) {
+	c.Writer.Header().Set("Content-Type", metrics.ContentType)
+
+	err := metrics.WriteText(c.Writer)
+	if err != nil {
+		h.Log.Errorf("couldn't write metrics: %s", err)
+	}
+}
+
+// Instrument observes latency of the handler and traces the request, name is used as the handler label.
+// The trace of the caller is continued if the request has traceparent header.
+func Instrument(name string, handle router.Handle) router.Handle {
+	return func(c *router.Control) {
+		start := time.Now()
+
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Start(ctx, c.Request.Method+" "+name, tracing.KindServer)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		handle(c)
+
+		code := c.GetCode()
+		if code == 0 {
+			code = http.StatusOK
+		}
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", name)
+		span.SetAttribute("http.status_code", code)
+		if code >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%s responds %d", name, code))
+		}
+
+		requestDuration.Observe(time.Since(start).Seconds(), name, strconv.Itoa(code))
+	}
+}
+
+// hookAction returns the action of the hook payload, it's empty if the event has no actions (e.g. push)
+func hookAction(payload []byte) string {
+	var evt struct {
+		Action string `json:"action"`
+	}
+
+	// the payload is already verified, events without action are counted with empty action
+	json.Unmarshal(payload, &evt)
+
+	return evt.Action
+}
@@ -1,12 +1,12 @@
 package handlers
 
 import (
-	"log"
 	"runtime/debug"
 
 	"github.com/takama/router"
 )
 
-func Panic(c *router.Control) {
-	log.Printf("Recovered panic:\n%s\n", string(debug.Stack()))
+// PanicHandler logs the recovered panic of a request handler
+func (h *Handler) PanicHandler(c *router.Control) {
+	h.log(c.Request.Context()).With("stack", string(debug.Stack())).Errorf("Recovered panic")
 }
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/k8s-community/cicd"
+	"github.com/k8s-community/github-integration/client"
+	"github.com/k8s-community/github-integration/github"
+	"github.com/k8s-community/github-integration/pipeline"
+)
+
+// maxDescriptionLength is the limit of commit status description on GitHub side
+const maxDescriptionLength = 140
+
+// repoConfig reads pipeline configuration from the commit. It returns nil and the service defaults are used
+// if the repository has no configuration, the installation can't read it (no Contents permission)
+// or it can't be read at all. Invalid configuration is reported like build results under client.ContextConfig,
+// so callbacks of the build which runs with defaults don't overwrite the report.
+func (h *Handler) repoConfig(ctx context.Context, installationID int, owner, repo, commit string) *pipeline.Config {
+	gh, err := h.githubClient(ctx, installationID)
+	if err != nil {
+		h.log(ctx).Errorf("couldn't init client for github, defaults are used: %s", err)
+		return nil
+	}
+
+	data, err := gh.FileContents(owner, repo, pipeline.FileName, commit)
+	if errors.Is(err, github.ErrForbidden) {
+		h.log(ctx).Infof("%s of %s/%s can't be read without Contents permission, defaults are used",
+			pipeline.FileName, owner, repo)
+		return nil
+	}
+	if err != nil {
+		h.log(ctx).Errorf("couldn't read %s, defaults are used: %s", pipeline.FileName, err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+
+	cfg, err := pipeline.Parse(data)
+	if err == nil {
+		return cfg
+	}
+
+	h.log(ctx).Warnf("%s in %s/%s (commit %s) is invalid, defaults are used: %s",
+		pipeline.FileName, owner, repo, commit, err)
+
+	description := fmt.Sprintf("Invalid %s: %s", pipeline.FileName, err)
+	if len(description) > maxDescriptionLength {
+		description = description[:maxDescriptionLength-3] + "..."
+	}
+	statusContext := client.ContextConfig
+
+	err = h.reportBuild(ctx, gh, &github.BuildCallback{
+		Username:    owner,
+		Repository:  repo,
+		CommitHash:  commit,
+		State:       client.StateFailure,
+		Description: &description,
+		Context:     &statusContext,
+	})
+	if err != nil {
+		h.log(ctx).Errorf("couldn't report invalid %s for %s/%s: %s", pipeline.FileName, owner, repo, err)
+	}
+
+	return nil
+}
+
+// applyRepoConfig overrides the service rule for the branch by the repository configuration.
+// It returns nil if the branch must not be built.
+func applyRepoConfig(rule *BranchRule, cfg *pipeline.Config, branch string) (*BranchRule, error) {
+	if !cfg.Build {
+		return nil, nil
+	}
+
+	// branches which are not deployed run the task of the repository
+	task := cfg.Task
+	if cfg.HasDeployBranches() {
+		if cfg.Deploys(branch) {
+			task = cicd.TaskDeploy
+		}
+	} else if rule != nil && rule.Task == cicd.TaskDeploy {
+		task = cicd.TaskDeploy
+	}
+
+	// version of the service rule is kept if the task is the same
+	if rule != nil && rule.Task == task {
+		return rule, nil
+	}
+
+	return newBranchRule(branch, task, "")
+}
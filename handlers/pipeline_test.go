@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/k8s-community/github-integration/client"
+	"github.com/k8s-community/github-integration/config"
+	"github.com/k8s-community/github-integration/github"
+	"github.com/k8s-community/github-integration/pipeline"
+)
+
+func TestInvalidRepoConfigReport(t *testing.T) {
+	for _, reporter := range []string{config.ReporterChecks, config.ReporterStatuses} {
+		gh := newFakeGitHub(t)
+		gh.files[pipeline.FileName] = "build: maybe\n"
+		h := testHandler(t, gh.URL, reporter)
+		ctx := context.Background()
+
+		if cfg := h.repoConfig(ctx, 1, "owner", "repo", "sha"); cfg != nil {
+			t.Fatalf("%s: invalid configuration must fall back to defaults, got %+v", reporter, cfg)
+		}
+
+		// the build runs with defaults and succeeds
+		gc, err := h.githubClient(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statusContext := client.ContextCICD
+		for _, state := range []string{client.StatePending, client.StateSuccess} {
+			err = h.reportBuild(ctx, gc, &github.BuildCallback{
+				Username:   "owner",
+				Repository: "repo",
+				CommitHash: "sha",
+				State:      state,
+				Context:    &statusContext,
+			})
+			if err != nil {
+				t.Fatalf("%s: %s", reporter, err)
+			}
+		}
+
+		if reporter == config.ReporterStatuses {
+			if state := gh.status(client.ContextConfig); state != client.StateFailure {
+				t.Errorf("%s: expected failed config status, got %q", reporter, state)
+			}
+			if state := gh.status(client.ContextCICD); state != client.StateSuccess {
+				t.Errorf("%s: expected successful build status, got %q", reporter, state)
+			}
+			continue
+		}
+
+		run := gh.checkRun(client.ContextConfig)
+		if run == nil || run.Conclusion == nil || *run.Conclusion != github.CheckConclusionFailure {
+			t.Fatalf("%s: expected failed config check run, got %+v", reporter, run)
+		}
+		if !strings.Contains(run.Output.Summary, pipeline.FileName) {
+			t.Errorf("%s: the check run must show the parse error, got %q", reporter, run.Output.Summary)
+		}
+		if build := gh.checkRun(client.ContextCICD); build == nil || *build.Conclusion != github.CheckConclusionSuccess {
+			t.Errorf("%s: expected successful build check run, got %+v", reporter, build)
+		}
+	}
+}
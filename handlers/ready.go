@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/k8s-community/github-integration/github"
+	"github.com/takama/router"
+)
+
+// readyTimeout limits time of every readiness check
+const readyTimeout = 2 * time.Second
+
+// Possible results of readiness checks
+const (
+	readyOK   = "ok"
+	readyFail = "fail"
+)
+
+// readyCheck is the result of a dependency check
+type readyCheck struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReadyzHandler checks dependencies of the service: DB, the private key of the integration and,
+// if GITHUBINT_READY_PROBE_SERVICES is true, CICD and user-manager services. It responds 503 if any check fails.
+func (h *Handler) ReadyzHandler(c *router.Control) {
+	checks := map[string]func(ctx context.Context) error{
+		"database":    h.checkDB,
+		"private_key": h.checkPrivateKey,
+	}
+
+	if h.Config.ReadyProbeServices {
+		checks["cicd"] = probeURL(h.Config.CICDBaseURL)
+		checks["user_manager"] = probeURL(h.Config.UsermanBaseURL)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]readyCheck, len(checks))
+	status := readyOK
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(ctx context.Context) error) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(ctx)
+			result := readyCheck{Status: readyOK, Latency: time.Since(start).String()}
+			if err != nil {
+				result.Status = readyFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = result
+			if err != nil {
+				status = readyFail
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	if status != readyOK {
+		code = http.StatusServiceUnavailable
+		h.log(c.Request.Context()).Warnf("service is not ready: %+v", results)
+	}
+
+	c.Code(code).Body(map[string]interface{}{
+		"status": status,
+		"checks": results,
+	})
+}
+
+// checkDB pings the database, queries aren't logged to keep logs clean of readiness probes
+func (h *Handler) checkDB(ctx context.Context) error {
+	if db, ok := h.DB.DBInterface().(*sql.DB); ok {
+		return db.PingContext(ctx)
+	}
+
+	var one int
+	return h.DB.DBInterface().QueryRow("SELECT 1").Scan(&one)
+}
+
+// checkPrivateKey checks that GITHUBINT_PRIV_KEY can sign tokens for GitHub API
+func (h *Handler) checkPrivateKey(ctx context.Context) error {
+	return github.ValidatePrivateKey(h.PrivateKey.Value())
+}
+
+// probeURL checks that the service responds, any HTTP status means that it's reachable
+func probeURL(baseURL string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequest("GET", baseURL, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%s responds %s", baseURL, resp.Status)
+		}
+
+		return nil
+	}
+}
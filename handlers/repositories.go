@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/k8s-community/github-integration/models"
+	"gopkg.in/reform.v1"
+	githubhook "gopkg.in/rjz/githubhook.v0"
+)
+
+// repositoriesResyncInterval is the shortest interval between syncs of repositories of an installation
+// which are caused by unknown repositories, so hooks of disabled repositories don't exhaust the rate limit
+const repositoriesResyncInterval = 10 * time.Minute
+
+// repositoriesResync limits syncs of repositories caused by unknown repositories
+var repositoriesResync = newSyncThrottle(repositoriesResyncInterval)
+
+// syncThrottle allows a sync of an installation once per interval
+type syncThrottle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[int]time.Time
+}
+
+func newSyncThrottle(interval time.Duration) *syncThrottle {
+	return &syncThrottle{interval: interval, last: make(map[int]time.Time)}
+}
+
+// allow checks if the installation may be synced now, the sync is recorded if it's allowed
+func (t *syncThrottle) allow(installationID int, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.last[installationID]; ok && now.Sub(last) < t.interval {
+		return false
+	}
+	t.last[installationID] = now
+
+	return true
+}
+
+// forget removes the sync of the installation, e.g. when it fails, so the next one is allowed
+func (t *syncThrottle) forget(installationID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.last, installationID)
+}
+
+// updateRepositories saves repositories added to the installation and removes repositories removed from it
+func (h *Handler) updateRepositories(ctx context.Context, hook *githubhook.Hook) error {
+	evt := github.InstallationRepositoriesEvent{}
+
+	err := hook.Extract(&evt)
+	if err != nil {
+		return err
+	}
+
+	if evt.Installation == nil || evt.Installation.ID == nil {
+		h.log(ctx).Warnf("Don't know how to process hook - no installation inside")
+		return nil
+	}
+
+	installationID := *evt.Installation.ID
+
+	for _, repo := range evt.RepositoriesAdded {
+		err = h.addRepository(ctx, installationID, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, repo := range evt.RepositoriesRemoved {
+		err = h.removeRepository(ctx, installationID, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	h.log(ctx).Infof("repositories of installation %d: %d added, %d removed",
+		installationID, len(evt.RepositoriesAdded), len(evt.RepositoriesRemoved))
+
+	return nil
+}
+
+// addRepository saves the repository of the installation, its name is updated if the repository is known
+func (h *Handler) addRepository(ctx context.Context, installationID int, repo *github.Repository) error {
+	if repo.ID == nil || repo.FullName == nil {
+		return nil
+	}
+
+	username, name := splitFullName(*repo.FullName)
+
+	var r = &models.InstallationRepository{}
+
+	st, err := h.db(ctx).SelectOneFrom(models.InstallationRepositoryTable,
+		"WHERE installation_id = $1 AND repository_id = $2", installationID, *repo.ID)
+	if err != nil && err != reform.ErrNoRows {
+		return err
+	}
+
+	if err == nil {
+		r = st.(*models.InstallationRepository)
+	}
+
+	r.InstallationID = installationID
+	r.RepositoryID = *repo.ID
+	r.Username = username
+	r.Repository = name
+
+	err = h.db(ctx).Save(r)
+	if err != nil {
+		return fmt.Errorf("couldn't save repository %s of installation %d: %s", *repo.FullName, installationID, err)
+	}
+
+	return nil
+}
+
+// syncRepositories replaces saved repositories of the installation with the repositories accessible via GitHub API
+func (h *Handler) syncRepositories(ctx context.Context, installationID int) error {
+	client, err := h.githubClient(ctx, installationID)
+	if err != nil {
+		return fmt.Errorf("couldn't init client for github: %s", err)
+	}
+
+	repos, err := client.InstallationRepositories()
+	if err != nil {
+		return err
+	}
+
+	err = h.db(ctx).InTransaction(func(tx *reform.TX) error {
+		_, err := tx.DeleteFrom(models.InstallationRepositoryTable, "WHERE installation_id = $1", installationID)
+		if err != nil {
+			return err
+		}
+
+		for _, repo := range repos {
+			err = tx.Insert(&models.InstallationRepository{
+				InstallationID: installationID,
+				RepositoryID:   repo.ID,
+				Username:       repo.Owner.Login,
+				Repository:     repo.Name,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't save repositories of installation %d: %s", installationID, err)
+	}
+
+	h.log(ctx).Infof("repositories of installation %d are synced: %d repositories", installationID, len(repos))
+
+	return nil
+}
+
+// repositoryEnabled checks if the repository is enabled for the installation.
+// Repositories of unknown installations are considered enabled; if the repository isn't saved,
+// the repositories are synced from GitHub API first, so installations saved before
+// repositories tracking aren't broken. Syncs are limited by repositoriesResyncInterval,
+// the repository is disabled if it isn't found after the recent sync.
+func (h *Handler) repositoryEnabled(ctx context.Context, instID int, username, repository string) (bool, error) {
+	inst, err := h.findInstallation(ctx, 0, instID, username)
+	if err == reform.ErrNoRows {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	installationID := inst.InstallationID
+
+	found, err := h.hasRepository(ctx, installationID, username, repository)
+	if err != nil || found {
+		return found, err
+	}
+
+	if !repositoriesResync.allow(installationID, time.Now()) {
+		h.log(ctx).Debugf("repository %s/%s isn't enabled, repositories of installation %d have been synced recently",
+			username, repository, installationID)
+		return false, nil
+	}
+
+	err = h.syncRepositories(ctx, installationID)
+	if err != nil {
+		repositoriesResync.forget(installationID)
+		return false, err
+	}
+
+	return h.hasRepository(ctx, installationID, username, repository)
+}
+
+// hasRepository checks if the repository is saved for the installation
+func (h *Handler) hasRepository(ctx context.Context, installationID int, username, repository string) (bool, error) {
+	_, err := h.db(ctx).SelectOneFrom(models.InstallationRepositoryTable,
+		"WHERE installation_id = $1 AND username = $2 AND repository = $3", installationID, username, repository)
+	if err == reform.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// removeRepository removes the repository from the installation
+func (h *Handler) removeRepository(ctx context.Context, installationID int, repo *github.Repository) error {
+	if repo.ID == nil {
+		return nil
+	}
+
+	_, err := h.db(ctx).DeleteFrom(models.InstallationRepositoryTable,
+		"WHERE installation_id = $1 AND repository_id = $2", installationID, *repo.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't remove repository %d of installation %d: %s", *repo.ID, installationID, err)
+	}
+
+	return nil
+}
+
+// removeRepositories removes all the repositories of the installation, e.g. when it is uninstalled
+func (h *Handler) removeRepositories(ctx context.Context, installationID int) error {
+	_, err := h.db(ctx).DeleteFrom(models.InstallationRepositoryTable, "WHERE installation_id = $1", installationID)
+	if err != nil {
+		return fmt.Errorf("couldn't remove repositories of installation %d: %s", installationID, err)
+	}
+
+	return nil
+}
+
+// splitFullName splits full name of repository (owner/name) into the owner and the name
+func splitFullName(fullName string) (owner, name string) {
+	parts := strings.SplitN(fullName, "/", 2)
+	if len(parts) < 2 {
+		return "", fullName
+	}
+
+	return parts[0], parts[1]
+}
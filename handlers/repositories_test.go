@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSyncThrottle(t *testing.T) {
+	throttle := newSyncThrottle(10 * time.Minute)
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		installationID int
+		at             time.Time
+		allowed        bool
+	}{
+		{1, now, true},
+		{1, now.Add(time.Minute), false},
+		// installations are throttled separately
+		{2, now.Add(time.Minute), true},
+		{1, now.Add(10*time.Minute - time.Second), false},
+		{1, now.Add(10 * time.Minute), true},
+		{1, now.Add(15 * time.Minute), false},
+	}
+
+	for i, test := range tests {
+		if allowed := throttle.allow(test.installationID, test.at); allowed != test.allowed {
+			t.Errorf("%d: installation %d at %s: expected %t, got %t", i, test.installationID, test.at, test.allowed, allowed)
+		}
+	}
+
+	// failed sync doesn't delay the next one
+	throttle.forget(1)
+	if !throttle.allow(1, now.Add(16*time.Minute)) {
+		t.Error("sync must be allowed after forget")
+	}
+}
+
+func TestSplitFullName(t *testing.T) {
+	tests := []struct {
+		fullName, owner, name string
+	}{
+		{"k8s-community/github-integration", "k8s-community", "github-integration"},
+		{"repo", "", "repo"},
+		{"owner/repo/extra", "owner", "repo/extra"},
+	}
+
+	for _, test := range tests {
+		owner, name := splitFullName(test.fullName)
+		if owner != test.owner || name != test.name {
+			t.Errorf("%s: expected %s and %s, got %s and %s", test.fullName, test.owner, test.name, owner, name)
+		}
+	}
+}
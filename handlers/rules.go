@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/k8s-community/cicd"
+	"github.com/k8s-community/github-integration/pipeline"
+)
+
+// defaultVersionTemplate is used by deploy rules without version template
+const defaultVersionTemplate = "{{.Branch}}"
+
+// BranchRule routes pushes to branches which match Pattern to CICD task
+type BranchRule struct {
+	Pattern string
+	Task    string
+	Version string
+
+	re      *regexp.Regexp
+	version *template.Template
+}
+
+// versionData is available in version templates of branch rules,
+// Suffix is the branch without the literal prefix of the pattern (text before the first wildcard)
+type versionData struct {
+	Branch      string
+	Suffix      string
+	Commit      string
+	ShortCommit string
+}
+
+// ParseBranchRules parses rules separated by ";", every rule has form
+// "pattern=task[:version template]", e.g. "master=deploy;release/*=deploy:{{.Branch}}-{{.ShortCommit}};*=test".
+// Pattern is a glob where "*" matches any sequence of characters (including "/"), "?" matches one character.
+// Rules are checked in order, the first matched rule wins.
+func ParseBranchRules(s string) ([]BranchRule, error) {
+	var rules []BranchRule
+
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("branch rule %q must have form pattern=task[:version]", item)
+		}
+
+		fields := strings.SplitN(parts[1], ":", 2)
+		if len(fields) < 2 {
+			fields = append(fields, "")
+		}
+
+		rule, err := newBranchRule(strings.TrimSpace(parts[0]), strings.TrimSpace(fields[0]), fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("branch rule %q: %s", item, err)
+		}
+
+		rules = append(rules, *rule)
+	}
+
+	return rules, nil
+}
+
+// newBranchRule validates the task and compiles pattern and version template of the rule
+func newBranchRule(pattern, task, version string) (*BranchRule, error) {
+	if task != cicd.TaskTest && task != cicd.TaskDeploy {
+		return nil, fmt.Errorf("unknown task %q, must be %s or %s", task, cicd.TaskTest, cicd.TaskDeploy)
+	}
+
+	if version == "" {
+		version = defaultVersionTemplate
+	}
+
+	tmpl, err := template.New(pattern).Option("missingkey=error").Parse(version)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse version template: %s", err)
+	}
+
+	// unknown fields are rejected on start instead of failing every push
+	err = tmpl.Execute(ioutil.Discard, versionData{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot render version template: %s", err)
+	}
+
+	rule := &BranchRule{
+		Pattern: pattern,
+		Task:    task,
+		Version: version,
+		re:      pipeline.GlobRegexp(pattern),
+		version: tmpl,
+	}
+
+	return rule, nil
+}
+
+// literalPrefix returns the pattern up to the first wildcard
+func literalPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?"); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+// MatchBranchRule returns the first rule which matches the branch or nil if there is no one
+func MatchBranchRule(rules []BranchRule, branch string) *BranchRule {
+	for i := range rules {
+		if rules[i].re.MatchString(branch) {
+			return &rules[i]
+		}
+	}
+
+	return nil
+}
+
+// VersionFor renders version of the deployment for the branch and commit. Empty version (e.g. .Suffix
+// of the branch which equals the prefix of the pattern) falls back to the branch and the short commit.
+func (r *BranchRule) VersionFor(branch, commit string) (string, error) {
+	data := versionData{
+		Branch:      branch,
+		Suffix:      strings.TrimPrefix(branch, literalPrefix(r.Pattern)),
+		Commit:      commit,
+		ShortCommit: commit,
+	}
+	if len(commit) > 7 {
+		data.ShortCommit = commit[:7]
+	}
+
+	var buf bytes.Buffer
+	err := r.version.Execute(&buf, data)
+	if err != nil {
+		return "", fmt.Errorf("cannot render version for branch %s by rule %s: %s", branch, r.Pattern, err)
+	}
+
+	version := strings.TrimSpace(buf.String())
+	if version == "" {
+		version = data.Branch + "-" + data.ShortCommit
+	}
+
+	return version, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/k8s-community/cicd"
+)
+
+func TestParseBranchRules(t *testing.T) {
+	rules, err := ParseBranchRules(" master=deploy ; release/*=deploy:{{.Branch}}-{{.ShortCommit}};;*=test ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []BranchRule{
+		{Pattern: "master", Task: cicd.TaskDeploy, Version: defaultVersionTemplate},
+		{Pattern: "release/*", Task: cicd.TaskDeploy, Version: "{{.Branch}}-{{.ShortCommit}}"},
+		{Pattern: "*", Task: cicd.TaskTest, Version: defaultVersionTemplate},
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %d rules, got %d", len(expected), len(rules))
+	}
+	for i, rule := range rules {
+		e := expected[i]
+		if rule.Pattern != e.Pattern || rule.Task != e.Task || rule.Version != e.Version {
+			t.Errorf("rule %d: expected %+v, got %+v", i, e, rule)
+		}
+	}
+}
+
+func TestParseBranchRulesErrors(t *testing.T) {
+	tests := []struct {
+		rules string
+		err   string
+	}{
+		{"master", "must have form pattern=task"},
+		{"=deploy", "must have form pattern=task"},
+		{"master=release", `unknown task "release"`},
+		{"master=", `unknown task ""`},
+		{"master=deploy:{{.Branch", "cannot parse version template"},
+		// environments aren't supported
+		{"master=deploy:{{.Environment}}", "cannot render version template"},
+		{"master=test;dev=build", `branch rule "dev=build": unknown task "build"`},
+	}
+
+	for _, test := range tests {
+		_, err := ParseBranchRules(test.rules)
+		if err == nil {
+			t.Errorf("%q: expected error %q", test.rules, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error %q, got %q", test.rules, test.err, err)
+		}
+	}
+}
+
+func TestMatchBranchRule(t *testing.T) {
+	rules, err := ParseBranchRules("master=deploy;release/*=deploy;feature/*=test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"master":        "master",
+		"release/1.0":   "release/*",
+		"feature/a/b":   "feature/*",
+		"master-backup": "",
+		"hotfix":        "",
+	}
+	for branch, pattern := range tests {
+		rule := MatchBranchRule(rules, branch)
+		switch {
+		case pattern == "" && rule != nil:
+			t.Errorf("%s must not match, got %s", branch, rule.Pattern)
+		case pattern != "" && (rule == nil || rule.Pattern != pattern):
+			t.Errorf("%s must match %s, got %+v", branch, pattern, rule)
+		}
+	}
+}
+
+func TestVersionFor(t *testing.T) {
+	tests := []struct {
+		rules   string
+		branch  string
+		version string
+	}{
+		{"master=deploy", "master", "master"},
+		{"release/*=deploy:{{.Suffix}}-{{.ShortCommit}}", "release/1.0", "1.0-0123456"},
+		{"*=deploy:{{.Commit}}", "dev", "0123456789abcdef"},
+		// compatibility rule of GITHUBINT_BRANCH: the rest of the branch name is the version
+		{"master*=deploy:{{.Suffix}}", "master-1.2", "-1.2"},
+		// exact prefix renders empty version, it falls back to the branch and the commit
+		{"master*=deploy:{{.Suffix}}", "master", "master-0123456"},
+		{"release/*=deploy:{{.Suffix}}", "release/", "release/-0123456"},
+	}
+
+	for _, test := range tests {
+		rules, err := ParseBranchRules(test.rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		version, err := MatchBranchRule(rules, test.branch).VersionFor(test.branch, "0123456789abcdef")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.rules, err)
+			continue
+		}
+		if version != test.version {
+			t.Errorf("%s for %s: expected %q, got %q", test.rules, test.branch, test.version, version)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/k8s-community/github-integration/models"
+	"gopkg.in/reform.v1"
+)
+
+// InstallationsDiff describes changes of saved installations made by SyncInstallations
+type InstallationsDiff struct {
+	Added       []string
+	Updated     []string
+	Deactivated []string
+}
+
+// Empty checks if there are no changes
+func (d *InstallationsDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Updated) == 0 && len(d.Deactivated) == 0
+}
+
+// SyncInstallations rebuilds saved installations from the installations listed by GitHub API:
+// unknown installations are added, changed ones are updated and missing ones are marked as deleted.
+// Nothing is saved in dry run mode, only the changes are returned.
+func (h *Handler) SyncInstallations(ctx context.Context, dryRun bool) (*InstallationsDiff, error) {
+	// integration endpoints don't need installation ID
+	client, err := h.githubClient(ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't init client for github: %s", err)
+	}
+
+	found, err := client.AppInstallations()
+	if err != nil {
+		return nil, err
+	}
+
+	sts, err := h.db(ctx).SelectAllFrom(models.InstallationTable, "ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	diff := new(InstallationsDiff)
+	synced := make(map[int64]bool, len(found))
+
+	for _, remote := range found {
+		acc := account{ID: remote.Account.ID, Type: remote.Account.Type, Login: remote.Account.Login}
+
+		status := models.InstallationActive
+		if remote.SuspendedAt != nil {
+			status = models.InstallationSuspended
+		}
+
+		inst, err := h.findInstallation(ctx, acc.ID, remote.ID, acc.Login)
+		switch {
+		case err == reform.ErrNoRows:
+			diff.Added = append(diff.Added, fmt.Sprintf("%s (%s, account ID %d): installation %d, %s",
+				acc.Login, acc.Type, acc.ID, remote.ID, status))
+
+		case err != nil:
+			return nil, err
+
+		default:
+			synced[inst.ID] = true
+
+			changes := installationChanges(inst, acc, remote.ID, status)
+			if changes == "" {
+				continue
+			}
+			diff.Updated = append(diff.Updated, fmt.Sprintf("%s: %s", inst.Username, changes))
+		}
+
+		if !dryRun {
+			err = h.updateInstallation(ctx, acc, remote.ID, status)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, st := range sts {
+		inst := st.(*models.Installation)
+		if synced[inst.ID] || inst.Status == models.InstallationDeleted {
+			continue
+		}
+
+		status := inst.Status
+		removed, err := h.deleteInstallation(ctx, inst, dryRun)
+		if err != nil {
+			return nil, err
+		}
+
+		diff.Deactivated = append(diff.Deactivated, fmt.Sprintf("%s: installation %d, %s -> %s, %d repositories removed",
+			inst.Username, inst.InstallationID, status, models.InstallationDeleted, removed))
+	}
+
+	return diff, nil
+}
+
+// deleteInstallation marks the installation as deleted and removes its repositories in one transaction,
+// like the deleted event does. Nothing is changed in dry run mode, the number of repositories is returned anyway.
+func (h *Handler) deleteInstallation(ctx context.Context, inst *models.Installation, dryRun bool) (removed uint, err error) {
+	if dryRun {
+		sts, err := h.db(ctx).SelectAllFrom(models.InstallationRepositoryTable,
+			"WHERE installation_id = $1", inst.InstallationID)
+		return uint(len(sts)), err
+	}
+
+	err = h.db(ctx).InTransaction(func(tx *reform.TX) error {
+		now := time.Now().UTC().Truncate(time.Second)
+		inst.Status = models.InstallationDeleted
+		inst.DeletedAt = &now
+
+		if err := tx.Save(inst); err != nil {
+			return err
+		}
+
+		removed, err = tx.DeleteFrom(models.InstallationRepositoryTable, "WHERE installation_id = $1", inst.InstallationID)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't delete installation %d of %s: %s", inst.InstallationID, inst.Username, err)
+	}
+
+	return removed, nil
+}
+
+// installationChanges describes differences between saved and listed installation, it's empty if they are the same
+func installationChanges(inst *models.Installation, acc account, instID int, status string) string {
+	var changes string
+
+	add := func(field string, from, to interface{}) {
+		if changes != "" {
+			changes += ", "
+		}
+		changes += fmt.Sprintf("%s %v -> %v", field, from, to)
+	}
+
+	if inst.InstallationID != instID {
+		add("installation", inst.InstallationID, instID)
+	}
+	if inst.AccountID != acc.ID {
+		add("account ID", inst.AccountID, acc.ID)
+	}
+	if inst.AccountType != acc.Type {
+		add("type", inst.AccountType, acc.Type)
+	}
+	if inst.Username != acc.Login {
+		add("login", inst.Username, acc.Login)
+	}
+	if inst.Status != status {
+		add("status", inst.Status, status)
+	}
+
+	return changes
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k8s-community/github-integration/config"
+	"github.com/k8s-community/github-integration/github"
+	"github.com/k8s-community/github-integration/models"
+)
+
+func TestSyncInstallationsRemovesRepositories(t *testing.T) {
+	db := testDB(t)
+	gh := newFakeGitHub(t)
+	h := testHandler(t, gh.URL, config.ReporterStatuses)
+	h.DB = db
+	ctx := context.Background()
+
+	// installation of owner is uninstalled while the service was down, installation of org is still listed
+	for _, inst := range []*models.Installation{
+		{AccountID: 1, AccountType: "User", Username: "owner", InstallationID: 10},
+		{AccountID: 2, AccountType: "Organization", Username: "org", InstallationID: 20},
+	} {
+		if err := db.Insert(inst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, repo := range []*models.InstallationRepository{
+		{InstallationID: 10, Username: "owner", Repository: "repo1"},
+		{InstallationID: 10, Username: "owner", Repository: "repo2"},
+		{InstallationID: 20, Username: "org", Repository: "repo"},
+	} {
+		repo.RepositoryID = i + 1
+		if err := db.Insert(repo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gh.installations = []*github.Installation{{ID: 20, Account: github.Account{ID: 2, Login: "org", Type: "Organization"}}}
+
+	repositories := func(installationID int) int {
+		sts, err := db.SelectAllFrom(models.InstallationRepositoryTable, "WHERE installation_id = $1", installationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(sts)
+	}
+	expected := "owner: installation 10, active -> deleted, 2 repositories removed"
+
+	// dry run shows the same changes as the real sync
+	for _, dryRun := range []bool{true, false} {
+		diff, err := h.SyncInstallations(ctx, dryRun)
+		if err != nil {
+			t.Fatalf("dry run %t: %s", dryRun, err)
+		}
+		if len(diff.Added) != 0 || len(diff.Updated) != 0 || len(diff.Deactivated) != 1 || diff.Deactivated[0] != expected {
+			t.Errorf("dry run %t: expected %q, got %+v", dryRun, expected, diff)
+		}
+
+		removed := 0
+		if dryRun {
+			removed = 2
+		}
+		if n := repositories(10); n != removed {
+			t.Errorf("dry run %t: expected %d repositories of the deleted installation, got %d", dryRun, removed, n)
+		}
+		if n := repositories(20); n != 1 {
+			t.Errorf("dry run %t: repositories of the listed installation must be kept, got %d", dryRun, n)
+		}
+	}
+
+	inst, err := h.findInstallation(ctx, 1, 10, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Status != models.InstallationDeleted || inst.DeletedAt == nil {
+		t.Errorf("expected deleted installation, got %+v", inst)
+	}
+
+	// deleted installations are up to date
+	diff, err := h.SyncInstallations(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("expected no changes, got %+v", diff)
+	}
+}
@@ -1,81 +1,174 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
-	"github.com/AlekSi/pointer"
 	"github.com/google/go-github/github"
 	"github.com/k8s-community/cicd"
 	"github.com/k8s-community/github-integration/models"
+	"github.com/k8s-community/github-integration/pipeline"
+	"github.com/k8s-community/github-integration/tracing"
 	userManClient "github.com/k8s-community/user-manager/client"
 	"github.com/takama/router"
-	"gopkg.in/reform.v1"
 	githubhook "gopkg.in/rjz/githubhook.v0"
 )
 
-// WebHookHandler is common handler for web hooks (installation, repositories installation, push)
+// userManSyncURL is the user activation method of user-manager service
+const userManSyncURL = "/sync-user"
+
+// WebHookHandler is common handler for web hooks (installation, repositories installation, push).
+// Verified hooks are stored in the inbox and acknowledged immediately, the work is done by inbox workers.
 func (h *Handler) WebHookHandler(c *router.Control) {
-	secret := []byte(h.Env["GITHUBINT_TOKEN"])
+	hook, err := h.parseHook(c.Request)
+	if err != nil {
+		h.log(c.Request.Context()).With("delivery", c.Request.Header.Get("X-GitHub-Delivery")).
+			Errorf("cannot parse hook: %s", err)
+		webhookDeliveries.Inc(c.Request.Header.Get("X-GitHub-Event"), "", "invalid")
+		c.Code(http.StatusBadRequest).Body(nil)
+		return
+	}
+
+	action := hookAction(hook.Payload)
 
-	hook, err := githubhook.Parse(secret, c.Request)
+	ctx := h.withLog(c.Request.Context(), "delivery", hook.Id, "event", hook.Event)
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttribute("github.delivery", hook.Id)
+	span.SetAttribute("github.event", hook.Event)
+
+	delivery, created, err := h.Inbox.Enqueue(ctx, hook)
 	if err != nil {
-		h.Errlog.Printf("cannot parse hook (ID %s): %s", hook.Id, err)
+		h.log(ctx).Errorf("cannot store hook: %s", err)
+		webhookDeliveries.Inc(hook.Event, action, "error")
+		c.Code(http.StatusInternalServerError).Body(nil)
+		return
+	}
+
+	if !created {
+		// the same delivery or the same event was already received, report the prior outcome
+		h.log(ctx).Infof("hook duplicates delivery %s (status = %s)", delivery.DeliveryID, delivery.Status)
+		webhookDeliveries.Inc(hook.Event, action, "duplicate")
+		c.Code(http.StatusOK).Body(map[string]string{
+			"delivery": delivery.DeliveryID,
+			"status":   delivery.Status,
+			"error":    delivery.LastError,
+		})
 		return
 	}
 
+	h.log(ctx).Infof("hook is queued")
+	webhookDeliveries.Inc(hook.Event, action, "queued")
+	c.Code(http.StatusAccepted).Body(nil)
+}
+
+// parseHook reads the hook and verifies its signature. The previous webhook secret is accepted
+// within the overlap window after rotation, because GitHub and the service can't switch to the new one at once.
+func (h *Handler) parseHook(req *http.Request) (*githubhook.Hook, error) {
+	hook, err := githubhook.New(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, secret := range h.WebhookSecret.Values() {
+		if hook.SignedBy(secret) {
+			if i > 0 {
+				h.log(req.Context()).With("delivery", hook.Id).Warnf("hook is signed by the previous webhook secret")
+			}
+			return hook, nil
+		}
+	}
+
+	return nil, errors.New("invalid signature")
+}
+
+// hookNaturalKey returns a key which identifies the event regardless of delivery,
+// e.g. the same push of the same commit. Empty key means that the event has no natural key.
+func hookNaturalKey(hook *githubhook.Hook) (string, error) {
+	switch hook.Event {
+	case "push":
+		evt := github.PushEvent{}
+		err := hook.Extract(&evt)
+		if err != nil {
+			return "", err
+		}
+
+		if evt.Repo == nil || evt.Repo.FullName == nil || evt.Ref == nil || evt.After == nil {
+			return "", nil
+		}
+
+		return fmt.Sprintf("push:%s:%s:%s", *evt.Repo.FullName, *evt.Ref, *evt.After), nil
+
+	case "create":
+		evt := github.CreateEvent{}
+		err := hook.Extract(&evt)
+		if err != nil {
+			return "", err
+		}
+
+		if evt.Repo == nil || evt.Repo.FullName == nil || evt.RefType == nil || evt.Ref == nil {
+			return "", nil
+		}
+
+		return fmt.Sprintf("create:%s:%s:%s", *evt.Repo.FullName, *evt.RefType, *evt.Ref), nil
+	}
+
+	return "", nil
+}
+
+// processHook does the actual work for the hook, it is called by inbox workers
+func (h *Handler) processHook(ctx context.Context, hook *githubhook.Hook) error {
+	var err error
+
 	switch hook.Event {
-	case "integration_installation":
+	case "installation", "integration_installation":
 		// Triggered when an integration has been installed or uninstalled by user.
-		h.Infolog.Printf("initialization web hook (ID %s)", hook.Id)
-		err = h.saveInstallation(hook)
+		// integration_installation is the deprecated name of the event.
+		h.log(ctx).Infof("initialization web hook")
+		err = h.saveInstallation(ctx, hook)
 
-	case "integration_installation_repositories":
+	case "installation_repositories", "integration_installation_repositories":
 		// Triggered when a repository is added or removed from an installation.
-		h.Infolog.Printf("initialization web hook for user repositories (ID %s)", hook.Id)
-		err = h.initialUserManagement(hook)
+		// integration_installation_repositories is the deprecated name of the event.
+		h.log(ctx).Infof("initialization web hook for user repositories")
+		err = h.updateRepositories(ctx, hook)
+		if err != nil {
+			break
+		}
+		err = h.initialUserManagement(ctx, hook)
+
+	case "installation_target":
+		// Triggered when the account which the integration is installed for is renamed.
+		h.log(ctx).Infof("installation target hook")
+		err = h.renameInstallation(ctx, hook)
 
 	case "push":
 		// Any Git push to a Repository, including editing tags or branches.
 		// Commits via API actions that update references are also counted. This is the default event.
-		h.Infolog.Printf("push hook (ID %s)", hook.Id)
-		err = h.processPush(c, hook)
-		if err != nil {
-			h.Infolog.Printf("cannot run ci/cd process for hook (ID %s): %s", hook.Id, err)
-			c.Code(http.StatusBadRequest).Body(nil)
-			return
-		}
+		h.log(ctx).Infof("push hook")
+		err = h.processPush(ctx, hook)
+
+	case "pull_request":
+		// Triggered when a pull request is opened, reopened or synchronized (new commits are pushed).
+		h.log(ctx).Infof("pull request hook")
+		err = h.processPullRequest(ctx, hook)
 
 	case "create":
-		h.Infolog.Printf("create hook (ID %s)", hook.Id)
-		// ToDo: keep it for the future
-		/*err = h.processCreate(c, hook)
-		if err != nil {
-			h.Infolog.Printf("cannot run ci/cd process for hook (ID %s): %s", hook.Id, err)
-			c.Code(http.StatusBadRequest).Body(nil)
-			return
-		}*/
-		return
+		// Triggered when a branch or a tag is created, release tags are deployed.
+		h.log(ctx).Infof("create hook")
+		err = h.processCreate(ctx, hook)
 
 	default:
-		h.Infolog.Printf("Warning! Don't know how to process hook (ID %s), event = %s", hook.Id, hook.Event)
-		c.Code(http.StatusOK).Body(nil)
-		return
+		h.log(ctx).Warnf("Don't know how to process hook - event %s", hook.Event)
 	}
 
-	if err != nil {
-		h.Errlog.Printf("cannot process hook (ID %s, event = %s): %s", hook.Id, hook.Event, err)
-		c.Code(http.StatusInternalServerError).Body(nil)
-		return
-	}
-
-	h.Infolog.Printf("finished to process hook (ID %s, event = %s)", hook.Id, hook.Event)
-	c.Code(http.StatusOK).Body(nil)
+	return err
 }
 
 // initialUserManagement is used for user activation in k8s system
-func (h *Handler) initialUserManagement(hook *githubhook.Hook) error {
+func (h *Handler) initialUserManagement(ctx context.Context, hook *githubhook.Hook) error {
 	evt := github.InstallationRepositoriesEvent{}
 
 	err := hook.Extract(&evt)
@@ -83,29 +176,44 @@ func (h *Handler) initialUserManagement(hook *githubhook.Hook) error {
 		return err
 	}
 
-	userManagerURL := h.Env["USERMAN_BASE_URL"]
+	userManagerURL := h.Config.UsermanBaseURL
 
-	client, err := userManClient.NewClient(nil, userManagerURL)
+	client, err := userManClient.NewClient(servicesClient, userManagerURL)
 	if err != nil {
 		return err
 	}
 
-	h.Infolog.Print("Try to activate (sync) user in k8s system: ", *evt.Sender.Login)
+	h.log(ctx).Infof("Try to activate (sync) user in k8s system: %s", *evt.Sender.Login)
 
 	user := userManClient.NewUser(*evt.Installation.Account.Login)
 
-	code, err := client.User.Sync(user)
+	// the request is built here instead of client.User.Sync to propagate the trace
+	req, err := client.NewRequest("PUT", userManSyncURL, user)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := client.Do(req, nil)
 	if err != nil {
 		return err
 	}
 
-	h.Infolog.Printf("Service user-man, method sync, returned code: %d", code)
+	h.log(ctx).Infof("Service user-man, method sync, returned code: %d", resp.StatusCode)
 
 	return nil
 }
 
+// pushEventOwner is the owner of the pushed repository, PushEventRepoOwner of go-github has no account ID
+type pushEventOwner struct {
+	Repository struct {
+		Owner *github.User `json:"owner,omitempty"`
+	} `json:"repository"`
+}
+
 // processPush is used for start CI/CD process for some repository from push hook
-func (h *Handler) processPush(c *router.Control, hook *githubhook.Hook) error {
+func (h *Handler) processPush(ctx context.Context, hook *githubhook.Hook) error {
 	evt := github.PushEvent{}
 
 	err := hook.Extract(&evt)
@@ -113,44 +221,135 @@ func (h *Handler) processPush(c *router.Control, hook *githubhook.Hook) error {
 		return err
 	}
 
+	owner := pushEventOwner{}
+	err = hook.Extract(&owner)
+	if err != nil {
+		return err
+	}
+
 	// ToDO: process somehow kind of hooks without HeadCommit
 	if evt.HeadCommit == nil {
-		h.Infolog.Printf("Warning! Don't know how to process hook %s - no HeadCommit inside", hook.Id)
+		h.log(ctx).Warnf("Don't know how to process hook - no HeadCommit inside")
 		return nil
 	}
 
-	h.setInstallationID(*evt.Repo.Owner.Name, *evt.Installation.ID)
+	ctx = h.withLog(ctx, "installation", *evt.Installation.ID, "repo", evt.Repo.GetFullName(), "commit", *evt.HeadCommit.ID)
+	acc := accountOf(owner.Repository.Owner)
+	if acc.Login == "" {
+		acc.Login = *evt.Repo.Owner.Name
+	}
+	h.setInstallationID(ctx, acc, *evt.Installation.ID)
 
-	prefix := "refs/heads/" + h.Env["GITHUBINT_BRANCH"]
-	if !strings.HasPrefix(*evt.Ref, prefix) {
-		h.Infolog.Printf("Warning! Don't know how to process hook %s - branch %s", hook.Id, *evt.Ref)
+	branch := strings.TrimPrefix(*evt.Ref, "refs/heads/")
+	if branch == *evt.Ref {
+		h.log(ctx).Warnf("Don't know how to process hook - ref %s is not a branch", *evt.Ref)
 		return nil
 	}
 
-	ciCdURL := h.Env["CICD_BASE_URL"]
+	rule := MatchBranchRule(h.BranchRules, branch)
 
-	client := cicd.NewClient(ciCdURL)
+	// configuration isn't read and invalid one isn't reported for repositories which aren't built
+	allowed, err := h.buildAllowed(ctx, *evt.Installation.ID, *evt.Repo.Owner.Name, *evt.Repo.Name)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		task := cicd.TaskTest
+		if rule != nil {
+			task = rule.Task
+		}
+		cicdDispatches.Inc(task, "skipped")
+		return nil
+	}
 
-	version := strings.Trim(*evt.Ref, prefix)
+	cfg := h.repoConfig(ctx, *evt.Installation.ID, *evt.Repo.Owner.Name, *evt.Repo.Name, *evt.HeadCommit.ID)
+
+	statusContext := ""
+	if cfg != nil {
+		rule, err = applyRepoConfig(rule, cfg, branch)
+		if err != nil {
+			return err
+		}
+		statusContext = cfg.Context
+	}
+
+	if rule == nil {
+		h.log(ctx).Warnf("Don't know how to process hook - no rule for branch %s", branch)
+		return nil
+	}
 
 	// run CICD process
 	req := &cicd.BuildRequest{
 		Username:   *evt.Repo.Owner.Name,
 		Repository: *evt.Repo.Name,
 		CommitHash: *evt.HeadCommit.ID,
-		Task:       cicd.TaskDeploy,
-		Version:    &version,
+		Task:       rule.Task,
+	}
+
+	if rule.Task == cicd.TaskDeploy {
+		version, err := rule.VersionFor(branch, *evt.HeadCommit.ID)
+		if err != nil {
+			return err
+		}
+		req.Version = &version
 	}
 
-	_, err = client.Build(req)
+	return h.startBuild(ctx, hook.Id, req, &models.Dispatch{
+		InstallationID: *evt.Installation.ID,
+		Context:        statusContext,
+	})
+}
+
+// processPullRequest is used for start CI process (tests) for the head commit of a pull request
+func (h *Handler) processPullRequest(ctx context.Context, hook *githubhook.Hook) error {
+	evt := github.PullRequestEvent{}
+
+	err := hook.Extract(&evt)
 	if err != nil {
-		return fmt.Errorf("cannot run ci/cd process for hook (ID %s): %s", hook.Id, err)
+		return err
 	}
 
-	return nil
+	action := evt.GetAction()
+	if action != "opened" && action != "synchronize" && action != "reopened" {
+		h.log(ctx).Warnf("Don't know how to process hook - pull request action %s", action)
+		return nil
+	}
+
+	if evt.PullRequest == nil || evt.PullRequest.Head == nil || evt.Repo == nil || evt.Installation == nil {
+		h.log(ctx).Warnf("Don't know how to process hook - no pull request inside")
+		return nil
+	}
+
+	// head repository of a pull request from fork is nil if the fork has been deleted
+	head := evt.PullRequest.Head
+	if head.Repo == nil || head.Repo.Owner == nil || head.SHA == nil {
+		h.log(ctx).Warnf("Don't know how to process hook - no head repository")
+		return nil
+	}
+
+	ctx = h.withLog(ctx, "installation", *evt.Installation.ID, "repo", evt.Repo.GetFullName(), "commit", *head.SHA)
+	h.setInstallationID(ctx, accountOf(evt.Repo.Owner), *evt.Installation.ID)
+
+	// run CI process against the head repository, it may be a fork
+	req := &cicd.BuildRequest{
+		Username:   *head.Repo.Owner.Login,
+		Repository: *head.Repo.Name,
+		CommitHash: *head.SHA,
+		Task:       cicd.TaskTest,
+	}
+
+	// statuses are posted on the head commit in the base repository to be shown in the pull request
+	dispatch := &models.Dispatch{
+		InstallationID:   *evt.Installation.ID,
+		TargetUsername:   *evt.Repo.Owner.Login,
+		TargetRepository: *evt.Repo.Name,
+		PullRequest:      evt.GetNumber(),
+	}
+
+	return h.dispatchBuild(ctx, hook.Id, req, dispatch)
 }
 
-func (h *Handler) processCreate(c *router.Control, hook *githubhook.Hook) error {
+func (h *Handler) processCreate(ctx context.Context, hook *githubhook.Hook) error {
 	evt := github.CreateEvent{}
 
 	err := hook.Extract(&evt)
@@ -159,81 +358,163 @@ func (h *Handler) processCreate(c *router.Control, hook *githubhook.Hook) error
 	}
 
 	// Process only tags
-	if evt.RefType == nil || *evt.RefType != "tag" {
-		h.Infolog.Printf("Warning! Don't know how to process hook %s - not a tag", hook.Id)
+	if evt.RefType == nil || *evt.RefType != "tag" || evt.Ref == nil {
+		h.log(ctx).Warnf("Don't know how to process hook - not a tag")
 		return nil
 	}
 
-	h.setInstallationID(*evt.Repo.Owner.Name, *evt.Installation.ID)
+	if evt.Repo == nil || evt.Repo.Owner == nil || evt.Installation == nil {
+		h.log(ctx).Warnf("Don't know how to process hook - no repository inside")
+		return nil
+	}
 
-	ciCdURL := h.Env["CICD_BASE_URL"]
+	tag := *evt.Ref
+	if !h.isReleaseTag(tag) {
+		h.log(ctx).Warnf("Don't know how to process hook - tag %s doesn't match %s", tag, h.Config.TagPatterns)
+		return nil
+	}
 
-	client := cicd.NewClient(ciCdURL)
+	owner := *evt.Repo.Owner.Login
+	ctx = h.withLog(ctx, "installation", *evt.Installation.ID, "repo", evt.Repo.GetFullName(), "tag", tag)
+	h.setInstallationID(ctx, accountOf(evt.Repo.Owner), *evt.Installation.ID)
+
+	client, err := h.githubClient(ctx, *evt.Installation.ID)
+	if err != nil {
+		return fmt.Errorf("couldn't init client for github: %s", err)
+	}
+
+	commit, err := client.ResolveTag(owner, *evt.Repo.Name, tag)
+	if err != nil {
+		return err
+	}
 
 	// run CICD process
 	req := &cicd.BuildRequest{
-		Username:   *evt.Repo.Owner.Name,
+		Username:   owner,
 		Repository: *evt.Repo.Name,
-		CommitHash: *evt.Ref,
+		CommitHash: commit,
 		Task:       cicd.TaskDeploy,
-		Version:    evt.Ref,
+		Version:    &tag,
 	}
 
-	_, err = client.Build(req)
-	if err != nil {
-		return fmt.Errorf("cannot run ci/cd process for hook (ID %s): %s", hook.Id, err)
+	return h.dispatchBuild(ctx, hook.Id, req, &models.Dispatch{InstallationID: *evt.Installation.ID})
+}
+
+// isReleaseTag checks if the tag matches one of GITHUBINT_TAG_PATTERNS (comma separated globs, e.g. v*),
+// globs are matched like patterns of branch rules
+func (h *Handler) isReleaseTag(tag string) bool {
+	for _, pattern := range strings.Split(h.Config.TagPatterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		if pipeline.GlobRegexp(pattern).MatchString(tag) {
+			return true
+		}
 	}
 
-	return nil
+	return false
 }
 
-// saveInstallation saves installation in memory
-func (h *Handler) saveInstallation(hook *githubhook.Hook) error {
-	evt := github.InstallationEvent{}
+// installationEvent is the installation event with repositories, which are sent when the integration is installed
+type installationEvent struct {
+	github.InstallationEvent
+	Repositories []*github.Repository `json:"repositories,omitempty"`
+}
+
+// saveInstallation tracks the state of installation: it is saved when the integration is installed,
+// marked as suspended or deleted when it is suspended or uninstalled by user
+func (h *Handler) saveInstallation(ctx context.Context, hook *githubhook.Hook) error {
+	evt := installationEvent{}
 
 	err := hook.Extract(&evt)
 	if err != nil {
 		return err
 	}
 
-	h.Infolog.Printf("save installation for user %s (installation ID = %d)", *evt.Sender.Login, *evt.Installation.ID)
-
-	// save installation for commit status update
-	err = h.setInstallationID(*evt.Installation.Account.Login, *evt.Installation.ID)
-	if err != nil {
-		h.Errlog.Printf("Couldn't save installation: %+v", err)
+	if evt.Installation == nil || evt.Installation.ID == nil || evt.Installation.Account == nil {
+		h.log(ctx).Warnf("Don't know how to process hook - no installation inside")
+		return nil
 	}
 
-	return nil
-}
+	acc := accountOf(evt.Installation.Account)
+	action := evt.GetAction()
+
+	ctx = h.withLog(ctx, "installation", *evt.Installation.ID)
+	h.log(ctx).Infof("%s installation for user %s", action, acc.Login)
+
+	switch action {
+	case "created":
+		// save installation for commit status update
+		err = h.updateInstallation(ctx, acc, *evt.Installation.ID, models.InstallationActive)
+		for _, repo := range evt.Repositories {
+			if err != nil {
+				break
+			}
+			err = h.addRepository(ctx, *evt.Installation.ID, repo)
+		}
 
-// installationID gets installation from DB
-func (h *Handler) installationID(username string) (*int, error) {
-	st, err := h.DB.FindOneFrom(models.InstallationTable, "username", username)
-	if err != nil {
-		return nil, err
+	case "new_permissions_accepted", "unsuspend":
+		// repositories may be changed while the installation was suspended
+		err = h.updateInstallation(ctx, acc, *evt.Installation.ID, models.InstallationActive)
+		if err != nil {
+			break
+		}
+		err = h.syncRepositories(ctx, *evt.Installation.ID)
+
+	case "suspend":
+		err = h.updateInstallation(ctx, acc, *evt.Installation.ID, models.InstallationSuspended)
+
+	case "deleted":
+		err = h.updateInstallation(ctx, acc, *evt.Installation.ID, models.InstallationDeleted)
+		if err != nil {
+			break
+		}
+		// the user isn't deactivated in k8s system: user-manager has no deactivation API
+		err = h.removeRepositories(ctx, *evt.Installation.ID)
+
+	default:
+		h.log(ctx).Warnf("Don't know how to process hook - installation action %s", action)
 	}
-	inst := st.(*models.Installation)
 
-	return pointer.ToInt(inst.InstallationID), nil
+	return err
+}
+
+// installationTargetEvent is triggered when the account of installation is renamed
+type installationTargetEvent struct {
+	Action       *string              `json:"action,omitempty"`
+	Account      *github.User         `json:"account,omitempty"`
+	Installation *github.Installation `json:"installation,omitempty"`
+	Changes      struct {
+		Login struct {
+			From string `json:"from"`
+		} `json:"login"`
+	} `json:"changes"`
 }
 
-func (h *Handler) setInstallationID(username string, instID int) error {
-	var inst = &models.Installation{}
+// renameInstallation updates login of the renamed account
+func (h *Handler) renameInstallation(ctx context.Context, hook *githubhook.Hook) error {
+	evt := installationTargetEvent{}
 
-	st, err := h.DB.FindOneFrom(models.InstallationTable, "username", username)
-	if err != nil && err != reform.ErrNoRows {
+	err := hook.Extract(&evt)
+	if err != nil {
 		return err
 	}
 
-	if err == nil {
-		inst = st.(*models.Installation)
+	if evt.Action == nil || *evt.Action != "renamed" {
+		h.log(ctx).Warnf("Don't know how to process hook - installation target action is not renamed")
+		return nil
 	}
 
-	inst.InstallationID = instID
-	inst.Username = username
+	if evt.Account == nil || evt.Installation == nil || evt.Installation.ID == nil {
+		h.log(ctx).Warnf("Don't know how to process hook - no account inside")
+		return nil
+	}
 
-	err = h.DB.Save(inst)
+	acc := accountOf(evt.Account)
+	ctx = h.withLog(ctx, "installation", *evt.Installation.ID)
+	h.log(ctx).Infof("rename installation from %s to %s", evt.Changes.Login.From, acc.Login)
 
-	return err
+	return h.updateInstallation(ctx, acc, *evt.Installation.ID, "")
 }
@@ -0,0 +1,213 @@
+// Package yaml parses the subset of YAML which is used by configuration files of the service and repositories.
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse parses the subset of YAML which is enough for pipeline and service configuration:
+// top-level "key: value" pairs, where value is a scalar, a flow list ([a, b])
+// or a block list of scalars ("- item" lines indented under the key). Comments are allowed.
+// Scalars are returned as strings, lists as []string. Nested maps and multiline scalars are rejected.
+func Parse(data []byte) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+
+	// list is the key of the block list which is being parsed
+	var list string
+
+	for n, line := range strings.Split(string(data), "\n") {
+		n++
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", n)
+		}
+
+		trimmed := strings.TrimLeft(line, " ")
+		indented := len(trimmed) < len(line)
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if list == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", n)
+			}
+
+			item := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+			if strings.HasPrefix(item, "{") || strings.HasPrefix(item, "[") || isKeyValue(item) {
+				return nil, fmt.Errorf("line %d: list items must be scalars", n)
+			}
+
+			item, err := unquote(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			values[list] = append(values[list].([]string), item)
+			continue
+		}
+
+		if indented {
+			return nil, fmt.Errorf("line %d: nested keys are not supported", n)
+		}
+
+		list = ""
+
+		parts := strings.SplitN(trimmed, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if key == "" {
+			return nil, fmt.Errorf("line %d: empty key", n)
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicated key %q", n, key)
+		}
+
+		switch {
+		case strings.HasPrefix(value, "{"):
+			return nil, fmt.Errorf("line %d: nested maps are not supported", n)
+
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			return nil, fmt.Errorf("line %d: multiline scalars are not supported", n)
+
+		case value == "":
+			// block list follows the key
+			list = key
+			values[key] = []string{}
+
+		case strings.HasPrefix(value, "["):
+			if !strings.HasSuffix(value, "]") {
+				return nil, fmt.Errorf("line %d: unterminated list", n)
+			}
+
+			items := []string{}
+			for _, item := range splitFlow(value[1 : len(value)-1]) {
+				item = strings.TrimSpace(item)
+				if item == "" {
+					continue
+				}
+				item, err := unquote(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s", n, err)
+				}
+				items = append(items, item)
+			}
+			values[key] = items
+
+		default:
+			s, err := unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			values[key] = s
+		}
+	}
+
+	return values, nil
+}
+
+// stripComment removes a comment which starts with " #" outside of quotes
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch {
+		case (line[i] == '"' || line[i] == '\'') && startsScalar(line[:i]):
+			end := quotedEnd(line, i)
+			if end < 0 {
+				// unterminated string is reported by unquote
+				return line
+			}
+			i = end - 1
+		case line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// startsScalar checks if a scalar starts after the prefix, so a quote there opens a quoted string.
+// Quotes within plain scalars (e.g. "it's") are literal characters.
+func startsScalar(prefix string) bool {
+	trimmed := strings.TrimRight(prefix, " \t")
+	switch {
+	case strings.TrimSpace(trimmed) == "" || strings.TrimSpace(trimmed) == "-":
+		return true
+	case strings.HasSuffix(trimmed, "[") || strings.HasSuffix(trimmed, ","):
+		return true
+	}
+
+	// the value of a key is separated by a space
+	return strings.HasSuffix(trimmed, ":") && len(trimmed) < len(prefix)
+}
+
+// quotedEnd returns the index after the quoted string which starts at i, it's -1 if the string is unterminated.
+// Backslash escapes a character in double quotes, a quote is doubled in single quotes.
+func quotedEnd(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case quote == '"' && s[j] == '\\':
+			j++
+		case quote == '\'' && s[j] == quote && j+1 < len(s) && s[j+1] == quote:
+			j++
+		case s[j] == quote:
+			return j + 1
+		}
+	}
+
+	return -1
+}
+
+// splitFlow splits items of a flow list by commas outside of quotes
+func splitFlow(s string) []string {
+	var items []string
+
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case (s[i] == '"' || s[i] == '\'') && startsScalar(s[start:i]):
+			end := quotedEnd(s, i)
+			if end < 0 {
+				return append(items, s[start:])
+			}
+			i = end - 1
+		case s[i] == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(items, s[start:])
+}
+
+// isKeyValue checks if the plain scalar looks like "key: value", it's a nested map in YAML
+func isKeyValue(s string) bool {
+	if s == "" || s[0] == '"' || s[0] == '\'' {
+		return false
+	}
+	return strings.HasSuffix(s, ":") || strings.Contains(s, ": ")
+}
+
+// unquote removes single or double quotes around the scalar
+func unquote(s string) (string, error) {
+	if len(s) < 2 {
+		return s, nil
+	}
+
+	switch {
+	case s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	case s[0] == '"' || s[0] == '\'':
+		return "", fmt.Errorf("unterminated quoted string %s", s)
+	}
+
+	return s, nil
+}
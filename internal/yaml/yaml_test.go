@@ -0,0 +1,75 @@
+package yaml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]interface{}
+	}{
+		{"empty", "", map[string]interface{}{}},
+		{"document start and comments", "---\n# comment\nbuild: true # trailing\n", map[string]interface{}{"build": "true"}},
+		{"hash inside value", "context: team#1\n", map[string]interface{}{"context": "team#1"}},
+		{"apostrophe in plain scalar", "name: it's here # comment\n", map[string]interface{}{"name": "it's here"}},
+		{"double quotes", `context: "my-team/ci # not a comment"`, map[string]interface{}{"context": "my-team/ci # not a comment"}},
+		{"escaped double quote", `context: "a \"b\" c" # comment`, map[string]interface{}{"context": `a "b" c`}},
+		{"single quotes", `context: 'it''s # not a comment'`, map[string]interface{}{"context": "it's # not a comment"}},
+		{"colon in value", "url: http://host:8080/path\n", map[string]interface{}{"url": "http://host:8080/path"}},
+		{"windows line ends", "task: test\r\nbuild: false\r\n", map[string]interface{}{"task": "test", "build": "false"}},
+		{"block list", "deploy:\n  - master\n  - 'release/*' # comment\n", map[string]interface{}{"deploy": []string{"master", "release/*"}}},
+		{"block list without indentation", "deploy:\n- master\ntask: test\n", map[string]interface{}{"deploy": []string{"master"}, "task": "test"}},
+		{"empty block list", "deploy:\ntask: test\n", map[string]interface{}{"deploy": []string{}, "task": "test"}},
+		{"flow list", "deploy: [master, release/*]\n", map[string]interface{}{"deploy": []string{"master", "release/*"}}},
+		{"empty flow list", "deploy: []\n", map[string]interface{}{"deploy": []string{}}},
+		{"quoted comma in flow list", `tags: ["v*,x", 'a, b', c]`, map[string]interface{}{"tags": []string{"v*,x", "a, b", "c"}}},
+	}
+
+	for _, test := range tests {
+		values, err := Parse([]byte(test.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("%s: expected %#v, got %#v", test.name, test.expected, values)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"tab indentation", "deploy:\n\t- master\n", "line 2: tabs are not allowed"},
+		{"list item without key", "- master\n", "line 1: list item without a key"},
+		{"nested keys", "deploy:\n  branch: master\n", "line 2: nested keys are not supported"},
+		{"nested flow map", "deploy: {branch: master}\n", "line 1: nested maps are not supported"},
+		{"map in list", "deploy:\n  - branch: master\n", "line 2: list items must be scalars"},
+		{"list in list", "deploy:\n  - [a, b]\n", "line 2: list items must be scalars"},
+		{"multiline scalar", "context: |\n  text\n", "line 1: multiline scalars are not supported"},
+		{"no colon", "build true\n", "line 1: expected \"key: value\""},
+		{"empty key", ": true\n", "line 1: empty key"},
+		{"duplicated key", "task: test\ntask: deploy\n", "line 2: duplicated key \"task\""},
+		{"unterminated list", "deploy: [master\n", "line 1: unterminated list"},
+		{"unterminated quote", "context: \"team\n", "line 1: unterminated quoted string"},
+		{"unterminated quote in list", "deploy: ['master, dev]\n", "line 1: unterminated quoted string"},
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test.input))
+		if err == nil {
+			t.Errorf("%s: expected error %q", test.name, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %q", test.name, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Package logging implements leveled structured logger which writes JSON or logfmt lines.
+// Fields attached by With are written with every line, so related lines can be correlated.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Level is a severity of log lines, lines below the level of logger are dropped
+type Level int
+
+// Levels of log lines
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+	LevelFatal: "fatal",
+}
+
+// String returns name of the level
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return strconv.Itoa(int(l))
+}
+
+// ParseLevel parses level name: debug, info, warn or error
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if level != LevelFatal && strings.EqualFold(name, levelName) {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q, use debug, info, warn or error", name)
+}
+
+// Formats of log lines
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// output is shared by the logger and loggers derived from it by With
+type output struct {
+	mu     sync.Mutex
+	w      io.Writer
+	level  Level
+	format string
+}
+
+// Logger writes leveled log lines with fields
+type Logger struct {
+	out    *output
+	fields []interface{}
+}
+
+// New creates a logger which writes lines of the level and above to w in the format (json or logfmt)
+func New(w io.Writer, level Level, format string) (*Logger, error) {
+	if format != FormatJSON && format != FormatLogfmt {
+		return nil, fmt.Errorf("unknown log format %q, use %s or %s", format, FormatJSON, FormatLogfmt)
+	}
+
+	return &Logger{out: &output{w: w, level: level, format: format}}, nil
+}
+
+// With returns a copy of the logger which adds the fields (key, value pairs) to every line
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, "")
+	}
+
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+
+	return &Logger{out: l.out, fields: fields}
+}
+
+// Enabled checks if lines of the level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.out.level
+}
+
+// Debugf writes a debug line
+func (l *Logger) Debugf(format string, args ...interface{}) {
+	l.write(LevelDebug, format, args)
+}
+
+// Infof writes an info line
+func (l *Logger) Infof(format string, args ...interface{}) {
+	l.write(LevelInfo, format, args)
+}
+
+// Warnf writes a warning line
+func (l *Logger) Warnf(format string, args ...interface{}) {
+	l.write(LevelWarn, format, args)
+}
+
+// Errorf writes an error line
+func (l *Logger) Errorf(format string, args ...interface{}) {
+	l.write(LevelError, format, args)
+}
+
+// Fatalf writes a fatal line and exits
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+	l.write(LevelFatal, format, args)
+	os.Exit(1)
+}
+
+func (l *Logger) write(level Level, format string, args []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	keyvals := make([]interface{}, 0, 6+len(l.fields))
+	keyvals = append(keyvals,
+		"time", time.Now().UTC().Format(time.RFC3339Nano),
+		"level", level.String(),
+		"msg", Redact(fmt.Sprintf(format, args...)))
+	keyvals = append(keyvals, l.fields...)
+
+	var buf bytes.Buffer
+	if l.out.format == FormatJSON {
+		writeJSON(&buf, keyvals)
+	} else {
+		writeLogfmt(&buf, keyvals)
+	}
+	buf.WriteByte('\n')
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+
+	l.out.w.Write(buf.Bytes())
+}
+
+func writeJSON(buf *bytes.Buffer, keyvals []interface{}) {
+	buf.WriteByte('{')
+	for i := 0; i < len(keyvals); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(keyvals[i]))
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := json.Marshal(redacted(keyvals[i+1]))
+		if err != nil {
+			value, _ = json.Marshal(Redact(fmt.Sprint(keyvals[i+1])))
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+}
+
+func writeLogfmt(buf *bytes.Buffer, keyvals []interface{}) {
+	for i := 0; i < len(keyvals); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(fmt.Sprint(keyvals[i]))
+		buf.WriteByte('=')
+
+		value := fmt.Sprint(redacted(keyvals[i+1]))
+		if needsQuotes(value) {
+			value = strconv.Quote(value)
+		}
+		buf.WriteString(value)
+	}
+}
+
+// plain converts values which have no useful JSON encoding to strings,
+// bytes are converted too so secrets aren't logged base64 encoded
+func plain(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return value
+}
+
+// redacted converts the value to plain one and replaces secrets in strings
+func redacted(value interface{}) interface{} {
+	value = plain(value)
+	if s, ok := value.(string); ok {
+		return Redact(s)
+	}
+	return value
+}
+
+func needsQuotes(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if unicode.IsSpace(r) || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx which carries the logger
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger of ctx, it's nil if ctx has no logger
+func FromContext(ctx context.Context) *Logger {
+	l, _ := ctx.Value(loggerKey{}).(*Logger)
+	return l
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secrets in log lines
+const Redacted = "[REDACTED]"
+
+// minSecretLength protects log lines from being redacted entirely because of a too short secret
+const minSecretLength = 6
+
+// patterns match secrets which are redacted even if they aren't registered:
+// PEM blocks of private keys, JWTs and GitHub tokens (installation tokens are v1.<hex> or ghs_<token>)
+var patterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9_]+`),
+	regexp.MustCompile(`\bv1\.[0-9a-f]{40}\b`),
+}
+
+// passwordPattern matches passwords of URLs in text, e.g. of DSN in DB errors
+var passwordPattern = regexp.MustCompile(`(://[^:/@\s]*:)[^@\s]+@`)
+
+var secrets struct {
+	sync.RWMutex
+	values []string
+}
+
+// RedactSecrets registers secret values (tokens, passwords, keys) which are replaced
+// in messages and fields of all loggers
+func RedactSecrets(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if len(value) >= minSecretLength {
+			secrets.values = append(secrets.values, value)
+		}
+	}
+}
+
+// Redact replaces registered secrets, private keys, tokens and URL passwords in s
+func Redact(s string) string {
+	for _, pattern := range patterns {
+		s = pattern.ReplaceAllString(s, Redacted)
+	}
+	s = passwordPattern.ReplaceAllString(s, "${1}"+Redacted+"@")
+
+	secrets.RLock()
+	defer secrets.RUnlock()
+
+	for _, value := range secrets.values {
+		s = strings.Replace(s, value, Redacted, -1)
+	}
+
+	return s
+}
+
+// RedactURL hides the password and query parameters of the URL, e.g. tokens of build logs
+func RedactURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return Redact(rawurl)
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, Redacted)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return Redact(u.String())
+}
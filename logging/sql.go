@@ -0,0 +1,33 @@
+package logging
+
+import (
+	"time"
+)
+
+// SQLLogger is reform logger which writes DB queries at debug level,
+// so queries are logged only if it's enabled explicitly
+type SQLLogger struct {
+	l *Logger
+}
+
+// NewSQLLogger creates SQLLogger which writes to the logger
+func NewSQLLogger(l *Logger) *SQLLogger {
+	return &SQLLogger{l: l}
+}
+
+// Before implements reform.Logger interface
+func (s *SQLLogger) Before(query string, args []interface{}) {}
+
+// After implements reform.Logger interface
+func (s *SQLLogger) After(query string, args []interface{}, d time.Duration, err error) {
+	if !s.l.Enabled(LevelDebug) {
+		return
+	}
+
+	// arguments aren't logged, they may contain payloads and secrets
+	l := s.l.With("query", query, "args", len(args), "duration", d)
+	if err != nil {
+		l = l.With("error", err)
+	}
+	l.Debugf("sql query")
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/reform.v1"
+)
+
+var dbQueryDuration = NewHistogramVec("githubint_db_query_duration_seconds",
+	"Latency of DB queries by operation (SELECT, INSERT, ...) and result.", nil, "operation", "result")
+
+// DBLogger is reform logger which observes latencies of DB queries and passes queries to the next logger
+type DBLogger struct {
+	next reform.Logger
+}
+
+// NewDBLogger creates DBLogger, next may be nil
+func NewDBLogger(next reform.Logger) *DBLogger {
+	return &DBLogger{next: next}
+}
+
+// Before implements reform.Logger interface
+func (l *DBLogger) Before(query string, args []interface{}) {
+	if l.next != nil {
+		l.next.Before(query, args)
+	}
+}
+
+// After implements reform.Logger interface
+func (l *DBLogger) After(query string, args []interface{}, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	dbQueryDuration.Observe(d.Seconds(), queryOperation(query), result)
+
+	if l.next != nil {
+		l.next.After(query, args, d, err)
+	}
+}
+
+// queryOperation returns the first keyword of SQL query in upper case
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,264 @@
+// Package metrics implements counters, gauges and histograms which are exposed
+// in Prometheus text format. Metrics are registered in the default registry on creation.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are histogram buckets for latencies in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Only backslash, double quote and line feed are escaped in text format,
+// other characters (including non-ASCII ones) are written as is
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+// collector is a metric family which can be written in text format
+type collector interface {
+	write(w io.Writer)
+}
+
+var registry = struct {
+	sync.Mutex
+	collectors []collector
+}{}
+
+func register(c collector) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.collectors = append(registry.collectors, c)
+}
+
+// WriteText writes all the registered metrics in Prometheus text format
+func WriteText(w io.Writer) error {
+	registry.Lock()
+	collectors := append([]collector(nil), registry.collectors...)
+	registry.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+
+	return buf.Flush()
+}
+
+// vec keeps values of a metric family by label values
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]interface{}
+}
+
+func newVec(name, help, kind string, labels []string) vec {
+	return vec{name: name, help: help, kind: kind, labels: labels, values: make(map[string]interface{})}
+}
+
+// get returns the value for label values, it is created by create if it doesn't exist. Caller holds the lock.
+func (v *vec) get(values []string, create func() interface{}) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	value, ok := v.values[key]
+	if !ok {
+		value = create()
+		v.values[key] = value
+	}
+
+	return value
+}
+
+// each calls f for values in stable order, label values are split from the key. Caller holds the lock.
+func (v *vec) each(f func(labels []string, value interface{})) {
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		var labels []string
+		if len(v.labels) > 0 {
+			labels = strings.Split(key, "\xff")
+		}
+		f(labels, v.values[key])
+	}
+}
+
+func (v *vec) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, helpEscaper.Replace(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.kind)
+}
+
+// labelPairs formats labels as {name="value",...}, extra pair is added if it's not empty
+func (v *vec) labelPairs(values []string, extraName, extraValue string) string {
+	var pairs []string
+	for i, name := range v.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, labelEscaper.Replace(extraValue)))
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec creates and registers a counter
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels)}
+	register(c)
+	return c
+}
+
+// Inc increments the counter for label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta to the counter for label values, delta must not be negative
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value := c.get(values, func() interface{} { return new(float64) }).(*float64)
+	*value += delta
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w)
+	c.each(func(labels []string, value interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(labels, "", ""), formatFloat(*value.(*float64)))
+	})
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	vec
+}
+
+// NewGaugeVec creates and registers a gauge
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labels)}
+	register(g)
+	return g
+}
+
+// Set sets the gauge for label values
+func (g *GaugeVec) Set(v float64, values ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	value := g.get(values, func() interface{} { return new(float64) }).(*float64)
+	*value = v
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.header(w)
+	g.each(func(labels []string, value interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(labels, "", ""), formatFloat(*value.(*float64)))
+	})
+}
+
+// histogram is a state of histogram for label values
+type histogram struct {
+	counts []uint64 // counts[i] is the count of observations <= buckets[i]
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec creates and registers a histogram, DefaultBuckets are used if buckets are nil
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	h := &HistogramVec{vec: newVec(name, help, "histogram", labels), buckets: buckets}
+	register(h)
+	return h
+}
+
+// Observe adds an observation for label values
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	value := h.get(values, func() interface{} {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	}).(*histogram)
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			value.counts[i]++
+		}
+	}
+	value.count++
+	value.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w)
+	h.each(func(labels []string, value interface{}) {
+		hist := value.(*histogram)
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(labels, "le", formatFloat(bound)), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(labels, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(labels, "", ""), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(labels, "", ""), hist.count)
+	})
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
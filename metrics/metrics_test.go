@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func output(c collector) string {
+	var buf bytes.Buffer
+	c.write(&buf)
+	return buf.String()
+}
+
+func TestCounterText(t *testing.T) {
+	c := NewCounterVec("test_requests_total", "Requests by path.\nPaths are C:\\ like.", "path", "code")
+	c.Inc("/b", "200")
+	c.Add(2.5, "/a", "500")
+	c.Inc("/a", "500")
+	c.Inc(`quote " backslash \ newline`+"\n"+`юникод`, "200")
+
+	expected := `# HELP test_requests_total Requests by path.\nPaths are C:\\ like.
+# TYPE test_requests_total counter
+test_requests_total{path="/a",code="500"} 3.5
+test_requests_total{path="/b",code="200"} 1
+test_requests_total{path="quote \" backslash \\ newline\nюникод",code="200"} 1
+`
+	if got := output(c); got != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestGaugeText(t *testing.T) {
+	g := NewGaugeVec("test_remaining", "Remaining calls.")
+	g.Set(5000)
+	g.Set(4999.5)
+
+	expected := `# HELP test_remaining Remaining calls.
+# TYPE test_remaining gauge
+test_remaining 4999.5
+`
+	if got := output(g); got != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestHistogramText(t *testing.T) {
+	h := NewHistogramVec("test_duration_seconds", "Duration.", []float64{0.1, 1, 2.5}, "handler")
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v, "webhook")
+	}
+
+	// buckets are cumulative, bounds are inclusive and +Inf bucket equals the count
+	expected := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{handler="webhook",le="0.1"} 2
+test_duration_seconds_bucket{handler="webhook",le="1"} 3
+test_duration_seconds_bucket{handler="webhook",le="2.5"} 3
+test_duration_seconds_bucket{handler="webhook",le="+Inf"} 4
+test_duration_seconds_sum{handler="webhook"} 3.65
+test_duration_seconds_count{handler="webhook"} 4
+`
+	if got := output(h); got != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	c := NewCounterVec("test_registered_total", "Registered counter.")
+	c.Inc()
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), "# TYPE test_registered_total counter\ntest_registered_total 1\n") {
+		t.Errorf("registered counter isn't written:\n%s", buf.String())
+	}
+}
+
+func TestLabelCountMismatch(t *testing.T) {
+	c := NewCounterVec("test_mismatch_total", "Mismatch.", "a", "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("wrong count of label values must panic")
+		}
+	}()
+	c.Inc("only one")
+}
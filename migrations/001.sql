@@ -0,0 +1,15 @@
+CREATE TABLE webhook_deliveries (
+  id              SERIAL PRIMARY KEY,
+  delivery_id     VARCHAR(128) NOT NULL UNIQUE,
+  event           VARCHAR(128) NOT NULL,
+  payload         TEXT         NOT NULL DEFAULT '',
+  status          VARCHAR(32)  NOT NULL DEFAULT 'pending',
+  attempts        INTEGER      NOT NULL DEFAULT 0,
+  last_error      TEXT         NOT NULL DEFAULT '',
+  next_attempt_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+
+  created_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+  updated_at      TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX webhook_deliveries_queue_idx ON webhook_deliveries (status, next_attempt_at);
@@ -0,0 +1,3 @@
+ALTER TABLE webhook_deliveries ADD COLUMN natural_key VARCHAR(512) NOT NULL DEFAULT '';
+
+CREATE INDEX webhook_deliveries_natural_key_idx ON webhook_deliveries (natural_key);
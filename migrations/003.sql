@@ -0,0 +1,18 @@
+CREATE TABLE build_dispatches (
+  id                SERIAL PRIMARY KEY,
+  delivery_id       VARCHAR(128) NOT NULL DEFAULT '',
+  request_id        VARCHAR(128) NOT NULL DEFAULT '',
+  task              VARCHAR(32)  NOT NULL,
+  username          VARCHAR(128) NOT NULL,
+  repository        VARCHAR(128) NOT NULL,
+  commit            VARCHAR(512) NOT NULL,
+  version           VARCHAR(256) NOT NULL DEFAULT '',
+  target_username   VARCHAR(128) NOT NULL,
+  target_repository VARCHAR(128) NOT NULL,
+  pull_request      INTEGER      NOT NULL DEFAULT 0,
+
+  created_at        TIMESTAMP    NOT NULL DEFAULT NOW(),
+  updated_at        TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX build_dispatches_commit_idx ON build_dispatches (username, repository, commit);
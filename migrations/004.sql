@@ -0,0 +1 @@
+ALTER TABLE build_dispatches ADD COLUMN environment VARCHAR(128) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE build_dispatches ADD COLUMN context VARCHAR(256) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE installations ADD COLUMN status       VARCHAR(32) NOT NULL DEFAULT 'active';
+ALTER TABLE installations ADD COLUMN suspended_at TIMESTAMP   NULL;
+ALTER TABLE installations ADD COLUMN deleted_at   TIMESTAMP   NULL;
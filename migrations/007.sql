@@ -0,0 +1,14 @@
+CREATE TABLE installation_repositories (
+  id              SERIAL PRIMARY KEY,
+  installation_id INTEGER      NOT NULL,
+  repository_id   INTEGER      NOT NULL,
+  username        VARCHAR(128) NOT NULL,
+  repository      VARCHAR(128) NOT NULL,
+
+  created_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+  updated_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+
+  UNIQUE (installation_id, repository_id)
+);
+
+CREATE INDEX installation_repositories_name_idx ON installation_repositories (username, repository);
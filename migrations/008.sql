@@ -0,0 +1,9 @@
+ALTER TABLE installations ADD COLUMN account_id   INTEGER     NOT NULL DEFAULT 0;
+ALTER TABLE installations ADD COLUMN account_type VARCHAR(32) NOT NULL DEFAULT '';
+
+-- login of renamed account may be taken by another account
+ALTER TABLE installations DROP CONSTRAINT installations_username_key;
+CREATE INDEX installations_username_idx ON installations (username);
+CREATE UNIQUE INDEX installations_account_id_idx ON installations (account_id) WHERE account_id <> 0;
+
+ALTER TABLE build_dispatches ADD COLUMN installation_id INTEGER NOT NULL DEFAULT 0;
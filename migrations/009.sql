@@ -0,0 +1,3 @@
+-- W3C trace context of the request which has received the web hook or requested the build
+ALTER TABLE webhook_deliveries ADD COLUMN trace_parent VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE build_dispatches ADD COLUMN trace_parent VARCHAR(64) NOT NULL DEFAULT '';
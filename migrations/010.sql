@@ -0,0 +1,2 @@
+-- CICD has no environments, environments of branch rules are dropped until it supports them
+ALTER TABLE build_dispatches DROP COLUMN environment;
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+//go:generate reform
+
+// Dispatch is a build request sent to CICD service. Commit statuses of the build
+// are posted to the target repository, which differs from the built one for pull requests from forks.
+//
+//reform:build_dispatches
+type Dispatch struct {
+	ID               int64  `reform:"id,pk"`
+	DeliveryID       string `reform:"delivery_id"`
+	InstallationID   int    `reform:"installation_id"`
+	RequestID        string `reform:"request_id"`
+	Task             string `reform:"task"`
+	Username         string `reform:"username"`
+	Repository       string `reform:"repository"`
+	Commit           string `reform:"commit"`
+	Version          string `reform:"version"`
+	TargetUsername   string `reform:"target_username"`
+	TargetRepository string `reform:"target_repository"`
+	PullRequest      int    `reform:"pull_request"`
+	Context          string `reform:"context"`
+	TraceParent      string `reform:"trace_parent"`
+
+	CreatedAt time.Time `reform:"created_at"`
+	UpdatedAt time.Time `reform:"updated_at"`
+}
+
+// BeforeInsert set CreatedAt and UpdatedAt.
+func (d *Dispatch) BeforeInsert() error {
+	d.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	d.UpdatedAt = d.CreatedAt
+	return nil
+}
+
+// BeforeUpdate set UpdatedAt.
+func (d *Dispatch) BeforeUpdate() error {
+	d.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	return nil
+}
@@ -0,0 +1,169 @@
+// Code generated by gopkg.in/reform.v1. DO NOT EDIT.
+
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/reform.v1"
+	"gopkg.in/reform.v1/parse"
+)
+
+type dispatchTableType struct {
+	s parse.StructInfo
+	z []interface{}
+}
+
+// Schema returns a schema name in SQL database ("").
+func (v *dispatchTableType) Schema() string {
+	return v.s.SQLSchema
+}
+
+// Name returns a view or table name in SQL database ("build_dispatches").
+func (v *dispatchTableType) Name() string {
+	return v.s.SQLName
+}
+
+// Columns returns a new slice of column names for that view or table in SQL database.
+func (v *dispatchTableType) Columns() []string {
+	return []string{"id", "delivery_id", "installation_id", "request_id", "task", "username", "repository", "commit", "version", "target_username", "target_repository", "pull_request", "context", "trace_parent", "created_at", "updated_at"}
+}
+
+// NewStruct makes a new struct for that view or table.
+func (v *dispatchTableType) NewStruct() reform.Struct {
+	return new(Dispatch)
+}
+
+// NewRecord makes a new record for that table.
+func (v *dispatchTableType) NewRecord() reform.Record {
+	return new(Dispatch)
+}
+
+// PKColumnIndex returns an index of primary key column for that table in SQL database.
+func (v *dispatchTableType) PKColumnIndex() uint {
+	return uint(v.s.PKFieldIndex)
+}
+
+// DispatchTable represents build_dispatches view or table in SQL database.
+var DispatchTable = &dispatchTableType{
+	s: parse.StructInfo{Type: "Dispatch", SQLSchema: "", SQLName: "build_dispatches", Fields: []parse.FieldInfo{{Name: "ID", Type: "int64", Column: "id"}, {Name: "DeliveryID", Type: "string", Column: "delivery_id"}, {Name: "InstallationID", Type: "int", Column: "installation_id"}, {Name: "RequestID", Type: "string", Column: "request_id"}, {Name: "Task", Type: "string", Column: "task"}, {Name: "Username", Type: "string", Column: "username"}, {Name: "Repository", Type: "string", Column: "repository"}, {Name: "Commit", Type: "string", Column: "commit"}, {Name: "Version", Type: "string", Column: "version"}, {Name: "TargetUsername", Type: "string", Column: "target_username"}, {Name: "TargetRepository", Type: "string", Column: "target_repository"}, {Name: "PullRequest", Type: "int", Column: "pull_request"}, {Name: "Context", Type: "string", Column: "context"}, {Name: "TraceParent", Type: "string", Column: "trace_parent"}, {Name: "CreatedAt", Type: "time.Time", Column: "created_at"}, {Name: "UpdatedAt", Type: "time.Time", Column: "updated_at"}}, PKFieldIndex: 0},
+	z: new(Dispatch).Values(),
+}
+
+// String returns a string representation of this struct or record.
+func (s Dispatch) String() string {
+	res := make([]string, 16)
+	res[0] = "ID: " + reform.Inspect(s.ID, true)
+	res[1] = "DeliveryID: " + reform.Inspect(s.DeliveryID, true)
+	res[2] = "InstallationID: " + reform.Inspect(s.InstallationID, true)
+	res[3] = "RequestID: " + reform.Inspect(s.RequestID, true)
+	res[4] = "Task: " + reform.Inspect(s.Task, true)
+	res[5] = "Username: " + reform.Inspect(s.Username, true)
+	res[6] = "Repository: " + reform.Inspect(s.Repository, true)
+	res[7] = "Commit: " + reform.Inspect(s.Commit, true)
+	res[8] = "Version: " + reform.Inspect(s.Version, true)
+	res[9] = "TargetUsername: " + reform.Inspect(s.TargetUsername, true)
+	res[10] = "TargetRepository: " + reform.Inspect(s.TargetRepository, true)
+	res[11] = "PullRequest: " + reform.Inspect(s.PullRequest, true)
+	res[12] = "Context: " + reform.Inspect(s.Context, true)
+	res[13] = "TraceParent: " + reform.Inspect(s.TraceParent, true)
+	res[14] = "CreatedAt: " + reform.Inspect(s.CreatedAt, true)
+	res[15] = "UpdatedAt: " + reform.Inspect(s.UpdatedAt, true)
+	return strings.Join(res, ", ")
+}
+
+// Values returns a slice of struct or record field values.
+// Returned interface{} values are never untyped nils.
+func (s *Dispatch) Values() []interface{} {
+	return []interface{}{
+		s.ID,
+		s.DeliveryID,
+		s.InstallationID,
+		s.RequestID,
+		s.Task,
+		s.Username,
+		s.Repository,
+		s.Commit,
+		s.Version,
+		s.TargetUsername,
+		s.TargetRepository,
+		s.PullRequest,
+		s.Context,
+		s.TraceParent,
+		s.CreatedAt,
+		s.UpdatedAt,
+	}
+}
+
+// Pointers returns a slice of pointers to struct or record fields.
+// Returned interface{} values are never untyped nils.
+func (s *Dispatch) Pointers() []interface{} {
+	return []interface{}{
+		&s.ID,
+		&s.DeliveryID,
+		&s.InstallationID,
+		&s.RequestID,
+		&s.Task,
+		&s.Username,
+		&s.Repository,
+		&s.Commit,
+		&s.Version,
+		&s.TargetUsername,
+		&s.TargetRepository,
+		&s.PullRequest,
+		&s.Context,
+		&s.TraceParent,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	}
+}
+
+// View returns View object for that struct.
+func (s *Dispatch) View() reform.View {
+	return DispatchTable
+}
+
+// Table returns Table object for that record.
+func (s *Dispatch) Table() reform.Table {
+	return DispatchTable
+}
+
+// PKValue returns a value of primary key for that record.
+// Returned interface{} value is never untyped nil.
+func (s *Dispatch) PKValue() interface{} {
+	return s.ID
+}
+
+// PKPointer returns a pointer to primary key field for that record.
+// Returned interface{} value is never untyped nil.
+func (s *Dispatch) PKPointer() interface{} {
+	return &s.ID
+}
+
+// HasPK returns true if record has non-zero primary key set, false otherwise.
+func (s *Dispatch) HasPK() bool {
+	return s.ID != DispatchTable.z[DispatchTable.s.PKFieldIndex]
+}
+
+// SetPK sets record primary key.
+func (s *Dispatch) SetPK(pk interface{}) {
+	if i64, ok := pk.(int64); ok {
+		s.ID = int64(i64)
+	} else {
+		s.ID = pk.(int64)
+	}
+}
+
+// check interfaces
+var (
+	_ reform.View   = DispatchTable
+	_ reform.Struct = (*Dispatch)(nil)
+	_ reform.Table  = DispatchTable
+	_ reform.Record = (*Dispatch)(nil)
+	_ fmt.Stringer  = (*Dispatch)(nil)
+)
+
+func init() {
+	parse.AssertUpToDate(&DispatchTable.s, new(Dispatch))
+}
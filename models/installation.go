@@ -9,13 +9,26 @@ const (
 	SourceGitHub = "github"
 )
 
+// Possible states of installations, builds are dispatched only for active installations
+const (
+	InstallationActive    = "active"
+	InstallationSuspended = "suspended"
+	InstallationDeleted   = "deleted"
+)
+
 //go:generate reform
 
 //reform:installations
 type Installation struct {
 	ID             int64  `reform:"id,pk"`
-	Username       string `reform:"username"`
+	AccountID      int    `reform:"account_id"`
+	AccountType    string `reform:"account_type"`
+	Username       string `reform:"username"` // current login of the account
 	InstallationID int    `reform:"installation_id"`
+	Status         string `reform:"status"`
+
+	SuspendedAt *time.Time `reform:"suspended_at"`
+	DeletedAt   *time.Time `reform:"deleted_at"`
 
 	CreatedAt time.Time `reform:"created_at"`
 	UpdatedAt time.Time `reform:"updated_at"`
@@ -25,6 +38,9 @@ type Installation struct {
 func (i *Installation) BeforeInsert() error {
 	i.CreatedAt = time.Now().UTC().Truncate(time.Second)
 	i.UpdatedAt = i.CreatedAt
+	if i.Status == "" {
+		i.Status = InstallationActive
+	}
 	return nil
 }
 
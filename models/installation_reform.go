@@ -27,7 +27,7 @@ func (v *installationTableType) Name() string {
 
 // Columns returns a new slice of column names for that view or table in SQL database.
 func (v *installationTableType) Columns() []string {
-	return []string{"id", "username", "installation_id", "created_at", "updated_at"}
+	return []string{"id", "account_id", "account_type", "username", "installation_id", "status", "suspended_at", "deleted_at", "created_at", "updated_at"}
 }
 
 // NewStruct makes a new struct for that view or table.
@@ -47,18 +47,23 @@ func (v *installationTableType) PKColumnIndex() uint {
 
 // InstallationTable represents installations view or table in SQL database.
 var InstallationTable = &installationTableType{
-	s: parse.StructInfo{Type: "Installation", SQLSchema: "", SQLName: "installations", Fields: []parse.FieldInfo{{Name: "ID", Type: "int64", Column: "id"}, {Name: "Username", Type: "string", Column: "username"}, {Name: "InstallationID", Type: "int", Column: "installation_id"}, {Name: "CreatedAt", Type: "time.Time", Column: "created_at"}, {Name: "UpdatedAt", Type: "time.Time", Column: "updated_at"}}, PKFieldIndex: 0},
+	s: parse.StructInfo{Type: "Installation", SQLSchema: "", SQLName: "installations", Fields: []parse.FieldInfo{{Name: "ID", Type: "int64", Column: "id"}, {Name: "AccountID", Type: "int", Column: "account_id"}, {Name: "AccountType", Type: "string", Column: "account_type"}, {Name: "Username", Type: "string", Column: "username"}, {Name: "InstallationID", Type: "int", Column: "installation_id"}, {Name: "Status", Type: "string", Column: "status"}, {Name: "SuspendedAt", Type: "*time.Time", Column: "suspended_at"}, {Name: "DeletedAt", Type: "*time.Time", Column: "deleted_at"}, {Name: "CreatedAt", Type: "time.Time", Column: "created_at"}, {Name: "UpdatedAt", Type: "time.Time", Column: "updated_at"}}, PKFieldIndex: 0},
 	z: new(Installation).Values(),
 }
 
 // String returns a string representation of this struct or record.
 func (s Installation) String() string {
-	res := make([]string, 5)
+	res := make([]string, 10)
 	res[0] = "ID: " + reform.Inspect(s.ID, true)
-	res[1] = "Username: " + reform.Inspect(s.Username, true)
-	res[2] = "InstallationID: " + reform.Inspect(s.InstallationID, true)
-	res[3] = "CreatedAt: " + reform.Inspect(s.CreatedAt, true)
-	res[4] = "UpdatedAt: " + reform.Inspect(s.UpdatedAt, true)
+	res[1] = "AccountID: " + reform.Inspect(s.AccountID, true)
+	res[2] = "AccountType: " + reform.Inspect(s.AccountType, true)
+	res[3] = "Username: " + reform.Inspect(s.Username, true)
+	res[4] = "InstallationID: " + reform.Inspect(s.InstallationID, true)
+	res[5] = "Status: " + reform.Inspect(s.Status, true)
+	res[6] = "SuspendedAt: " + reform.Inspect(s.SuspendedAt, true)
+	res[7] = "DeletedAt: " + reform.Inspect(s.DeletedAt, true)
+	res[8] = "CreatedAt: " + reform.Inspect(s.CreatedAt, true)
+	res[9] = "UpdatedAt: " + reform.Inspect(s.UpdatedAt, true)
 	return strings.Join(res, ", ")
 }
 
@@ -67,8 +72,13 @@ func (s Installation) String() string {
 func (s *Installation) Values() []interface{} {
 	return []interface{}{
 		s.ID,
+		s.AccountID,
+		s.AccountType,
 		s.Username,
 		s.InstallationID,
+		s.Status,
+		s.SuspendedAt,
+		s.DeletedAt,
 		s.CreatedAt,
 		s.UpdatedAt,
 	}
@@ -79,8 +89,13 @@ func (s *Installation) Values() []interface{} {
 func (s *Installation) Pointers() []interface{} {
 	return []interface{}{
 		&s.ID,
+		&s.AccountID,
+		&s.AccountType,
 		&s.Username,
 		&s.InstallationID,
+		&s.Status,
+		&s.SuspendedAt,
+		&s.DeletedAt,
 		&s.CreatedAt,
 		&s.UpdatedAt,
 	}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+//go:generate reform
+
+// InstallationRepository is a repository enabled for the installation
+//
+//reform:installation_repositories
+type InstallationRepository struct {
+	ID             int64  `reform:"id,pk"`
+	InstallationID int    `reform:"installation_id"`
+	RepositoryID   int    `reform:"repository_id"`
+	Username       string `reform:"username"`
+	Repository     string `reform:"repository"`
+
+	CreatedAt time.Time `reform:"created_at"`
+	UpdatedAt time.Time `reform:"updated_at"`
+}
+
+// BeforeInsert set CreatedAt and UpdatedAt.
+func (r *InstallationRepository) BeforeInsert() error {
+	r.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	r.UpdatedAt = r.CreatedAt
+	return nil
+}
+
+// BeforeUpdate set UpdatedAt.
+func (r *InstallationRepository) BeforeUpdate() error {
+	r.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	return nil
+}
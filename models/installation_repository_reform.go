@@ -0,0 +1,142 @@
+// Code generated by gopkg.in/reform.v1. DO NOT EDIT.
+
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/reform.v1"
+	"gopkg.in/reform.v1/parse"
+)
+
+type installationRepositoryTableType struct {
+	s parse.StructInfo
+	z []interface{}
+}
+
+// Schema returns a schema name in SQL database ("").
+func (v *installationRepositoryTableType) Schema() string {
+	return v.s.SQLSchema
+}
+
+// Name returns a view or table name in SQL database ("installation_repositories").
+func (v *installationRepositoryTableType) Name() string {
+	return v.s.SQLName
+}
+
+// Columns returns a new slice of column names for that view or table in SQL database.
+func (v *installationRepositoryTableType) Columns() []string {
+	return []string{"id", "installation_id", "repository_id", "username", "repository", "created_at", "updated_at"}
+}
+
+// NewStruct makes a new struct for that view or table.
+func (v *installationRepositoryTableType) NewStruct() reform.Struct {
+	return new(InstallationRepository)
+}
+
+// NewRecord makes a new record for that table.
+func (v *installationRepositoryTableType) NewRecord() reform.Record {
+	return new(InstallationRepository)
+}
+
+// PKColumnIndex returns an index of primary key column for that table in SQL database.
+func (v *installationRepositoryTableType) PKColumnIndex() uint {
+	return uint(v.s.PKFieldIndex)
+}
+
+// InstallationRepositoryTable represents installation_repositories view or table in SQL database.
+var InstallationRepositoryTable = &installationRepositoryTableType{
+	s: parse.StructInfo{Type: "InstallationRepository", SQLSchema: "", SQLName: "installation_repositories", Fields: []parse.FieldInfo{{Name: "ID", Type: "int64", Column: "id"}, {Name: "InstallationID", Type: "int", Column: "installation_id"}, {Name: "RepositoryID", Type: "int", Column: "repository_id"}, {Name: "Username", Type: "string", Column: "username"}, {Name: "Repository", Type: "string", Column: "repository"}, {Name: "CreatedAt", Type: "time.Time", Column: "created_at"}, {Name: "UpdatedAt", Type: "time.Time", Column: "updated_at"}}, PKFieldIndex: 0},
+	z: new(InstallationRepository).Values(),
+}
+
+// String returns a string representation of this struct or record.
+func (s InstallationRepository) String() string {
+	res := make([]string, 7)
+	res[0] = "ID: " + reform.Inspect(s.ID, true)
+	res[1] = "InstallationID: " + reform.Inspect(s.InstallationID, true)
+	res[2] = "RepositoryID: " + reform.Inspect(s.RepositoryID, true)
+	res[3] = "Username: " + reform.Inspect(s.Username, true)
+	res[4] = "Repository: " + reform.Inspect(s.Repository, true)
+	res[5] = "CreatedAt: " + reform.Inspect(s.CreatedAt, true)
+	res[6] = "UpdatedAt: " + reform.Inspect(s.UpdatedAt, true)
+	return strings.Join(res, ", ")
+}
+
+// Values returns a slice of struct or record field values.
+// Returned interface{} values are never untyped nils.
+func (s *InstallationRepository) Values() []interface{} {
+	return []interface{}{
+		s.ID,
+		s.InstallationID,
+		s.RepositoryID,
+		s.Username,
+		s.Repository,
+		s.CreatedAt,
+		s.UpdatedAt,
+	}
+}
+
+// Pointers returns a slice of pointers to struct or record fields.
+// Returned interface{} values are never untyped nils.
+func (s *InstallationRepository) Pointers() []interface{} {
+	return []interface{}{
+		&s.ID,
+		&s.InstallationID,
+		&s.RepositoryID,
+		&s.Username,
+		&s.Repository,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	}
+}
+
+// View returns View object for that struct.
+func (s *InstallationRepository) View() reform.View {
+	return InstallationRepositoryTable
+}
+
+// Table returns Table object for that record.
+func (s *InstallationRepository) Table() reform.Table {
+	return InstallationRepositoryTable
+}
+
+// PKValue returns a value of primary key for that record.
+// Returned interface{} value is never untyped nil.
+func (s *InstallationRepository) PKValue() interface{} {
+	return s.ID
+}
+
+// PKPointer returns a pointer to primary key field for that record.
+// Returned interface{} value is never untyped nil.
+func (s *InstallationRepository) PKPointer() interface{} {
+	return &s.ID
+}
+
+// HasPK returns true if record has non-zero primary key set, false otherwise.
+func (s *InstallationRepository) HasPK() bool {
+	return s.ID != InstallationRepositoryTable.z[InstallationRepositoryTable.s.PKFieldIndex]
+}
+
+// SetPK sets record primary key.
+func (s *InstallationRepository) SetPK(pk interface{}) {
+	if i64, ok := pk.(int64); ok {
+		s.ID = int64(i64)
+	} else {
+		s.ID = pk.(int64)
+	}
+}
+
+// check interfaces
+var (
+	_ reform.View   = InstallationRepositoryTable
+	_ reform.Struct = (*InstallationRepository)(nil)
+	_ reform.Table  = InstallationRepositoryTable
+	_ reform.Record = (*InstallationRepository)(nil)
+	_ fmt.Stringer  = (*InstallationRepository)(nil)
+)
+
+func init() {
+	parse.AssertUpToDate(&InstallationRepositoryTable.s, new(InstallationRepository))
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Possible states of webhook deliveries
+const (
+	DeliveryPending    = "pending"
+	DeliveryProcessing = "processing"
+	DeliveryDone       = "done"
+	DeliveryFailed     = "failed"
+)
+
+//go:generate reform
+
+//reform:webhook_deliveries
+type WebhookDelivery struct {
+	ID            int64     `reform:"id,pk"`
+	DeliveryID    string    `reform:"delivery_id"`
+	Event         string    `reform:"event"`
+	NaturalKey    string    `reform:"natural_key"`
+	Payload       string    `reform:"payload"`
+	Status        string    `reform:"status"`
+	Attempts      int       `reform:"attempts"`
+	LastError     string    `reform:"last_error"`
+	NextAttemptAt time.Time `reform:"next_attempt_at"`
+	TraceParent   string    `reform:"trace_parent"`
+
+	CreatedAt time.Time `reform:"created_at"`
+	UpdatedAt time.Time `reform:"updated_at"`
+}
+
+// BeforeInsert set CreatedAt and UpdatedAt.
+func (d *WebhookDelivery) BeforeInsert() error {
+	d.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	d.UpdatedAt = d.CreatedAt
+	if d.NextAttemptAt.IsZero() {
+		d.NextAttemptAt = d.CreatedAt
+	}
+	return nil
+}
+
+// BeforeUpdate set UpdatedAt.
+func (d *WebhookDelivery) BeforeUpdate() error {
+	d.UpdatedAt = time.Now().UTC().Truncate(time.Second)
+	return nil
+}
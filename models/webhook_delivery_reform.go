@@ -0,0 +1,157 @@
+// Code generated by gopkg.in/reform.v1. DO NOT EDIT.
+
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/reform.v1"
+	"gopkg.in/reform.v1/parse"
+)
+
+type webhookDeliveryTableType struct {
+	s parse.StructInfo
+	z []interface{}
+}
+
+// Schema returns a schema name in SQL database ("").
+func (v *webhookDeliveryTableType) Schema() string {
+	return v.s.SQLSchema
+}
+
+// Name returns a view or table name in SQL database ("webhook_deliveries").
+func (v *webhookDeliveryTableType) Name() string {
+	return v.s.SQLName
+}
+
+// Columns returns a new slice of column names for that view or table in SQL database.
+func (v *webhookDeliveryTableType) Columns() []string {
+	return []string{"id", "delivery_id", "event", "natural_key", "payload", "status", "attempts", "last_error", "next_attempt_at", "trace_parent", "created_at", "updated_at"}
+}
+
+// NewStruct makes a new struct for that view or table.
+func (v *webhookDeliveryTableType) NewStruct() reform.Struct {
+	return new(WebhookDelivery)
+}
+
+// NewRecord makes a new record for that table.
+func (v *webhookDeliveryTableType) NewRecord() reform.Record {
+	return new(WebhookDelivery)
+}
+
+// PKColumnIndex returns an index of primary key column for that table in SQL database.
+func (v *webhookDeliveryTableType) PKColumnIndex() uint {
+	return uint(v.s.PKFieldIndex)
+}
+
+// WebhookDeliveryTable represents webhook_deliveries view or table in SQL database.
+var WebhookDeliveryTable = &webhookDeliveryTableType{
+	s: parse.StructInfo{Type: "WebhookDelivery", SQLSchema: "", SQLName: "webhook_deliveries", Fields: []parse.FieldInfo{{Name: "ID", Type: "int64", Column: "id"}, {Name: "DeliveryID", Type: "string", Column: "delivery_id"}, {Name: "Event", Type: "string", Column: "event"}, {Name: "NaturalKey", Type: "string", Column: "natural_key"}, {Name: "Payload", Type: "string", Column: "payload"}, {Name: "Status", Type: "string", Column: "status"}, {Name: "Attempts", Type: "int", Column: "attempts"}, {Name: "LastError", Type: "string", Column: "last_error"}, {Name: "NextAttemptAt", Type: "time.Time", Column: "next_attempt_at"}, {Name: "TraceParent", Type: "string", Column: "trace_parent"}, {Name: "CreatedAt", Type: "time.Time", Column: "created_at"}, {Name: "UpdatedAt", Type: "time.Time", Column: "updated_at"}}, PKFieldIndex: 0},
+	z: new(WebhookDelivery).Values(),
+}
+
+// String returns a string representation of this struct or record.
+func (s WebhookDelivery) String() string {
+	res := make([]string, 12)
+	res[0] = "ID: " + reform.Inspect(s.ID, true)
+	res[1] = "DeliveryID: " + reform.Inspect(s.DeliveryID, true)
+	res[2] = "Event: " + reform.Inspect(s.Event, true)
+	res[3] = "NaturalKey: " + reform.Inspect(s.NaturalKey, true)
+	res[4] = "Payload: " + reform.Inspect(s.Payload, true)
+	res[5] = "Status: " + reform.Inspect(s.Status, true)
+	res[6] = "Attempts: " + reform.Inspect(s.Attempts, true)
+	res[7] = "LastError: " + reform.Inspect(s.LastError, true)
+	res[8] = "NextAttemptAt: " + reform.Inspect(s.NextAttemptAt, true)
+	res[9] = "TraceParent: " + reform.Inspect(s.TraceParent, true)
+	res[10] = "CreatedAt: " + reform.Inspect(s.CreatedAt, true)
+	res[11] = "UpdatedAt: " + reform.Inspect(s.UpdatedAt, true)
+	return strings.Join(res, ", ")
+}
+
+// Values returns a slice of struct or record field values.
+// Returned interface{} values are never untyped nils.
+func (s *WebhookDelivery) Values() []interface{} {
+	return []interface{}{
+		s.ID,
+		s.DeliveryID,
+		s.Event,
+		s.NaturalKey,
+		s.Payload,
+		s.Status,
+		s.Attempts,
+		s.LastError,
+		s.NextAttemptAt,
+		s.TraceParent,
+		s.CreatedAt,
+		s.UpdatedAt,
+	}
+}
+
+// Pointers returns a slice of pointers to struct or record fields.
+// Returned interface{} values are never untyped nils.
+func (s *WebhookDelivery) Pointers() []interface{} {
+	return []interface{}{
+		&s.ID,
+		&s.DeliveryID,
+		&s.Event,
+		&s.NaturalKey,
+		&s.Payload,
+		&s.Status,
+		&s.Attempts,
+		&s.LastError,
+		&s.NextAttemptAt,
+		&s.TraceParent,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	}
+}
+
+// View returns View object for that struct.
+func (s *WebhookDelivery) View() reform.View {
+	return WebhookDeliveryTable
+}
+
+// Table returns Table object for that record.
+func (s *WebhookDelivery) Table() reform.Table {
+	return WebhookDeliveryTable
+}
+
+// PKValue returns a value of primary key for that record.
+// Returned interface{} value is never untyped nil.
+func (s *WebhookDelivery) PKValue() interface{} {
+	return s.ID
+}
+
+// PKPointer returns a pointer to primary key field for that record.
+// Returned interface{} value is never untyped nil.
+func (s *WebhookDelivery) PKPointer() interface{} {
+	return &s.ID
+}
+
+// HasPK returns true if record has non-zero primary key set, false otherwise.
+func (s *WebhookDelivery) HasPK() bool {
+	return s.ID != WebhookDeliveryTable.z[WebhookDeliveryTable.s.PKFieldIndex]
+}
+
+// SetPK sets record primary key.
+func (s *WebhookDelivery) SetPK(pk interface{}) {
+	if i64, ok := pk.(int64); ok {
+		s.ID = int64(i64)
+	} else {
+		s.ID = pk.(int64)
+	}
+}
+
+// check interfaces
+var (
+	_ reform.View   = WebhookDeliveryTable
+	_ reform.Struct = (*WebhookDelivery)(nil)
+	_ reform.Table  = WebhookDeliveryTable
+	_ reform.Record = (*WebhookDelivery)(nil)
+	_ fmt.Stringer  = (*WebhookDelivery)(nil)
+)
+
+func init() {
+	parse.AssertUpToDate(&WebhookDeliveryTable.s, new(WebhookDelivery))
+}
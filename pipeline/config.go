@@ -0,0 +1,132 @@
+// Package pipeline describes per-repository pipeline configuration, which is read from
+// the .k8s-community.yml file in the root of a repository.
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/k8s-community/cicd"
+	"github.com/k8s-community/github-integration/internal/yaml"
+)
+
+// FileName is the name of pipeline configuration file in the root of a repository
+const FileName = ".k8s-community.yml"
+
+// Config is a pipeline configuration of a repository, e.g.
+//
+//	build: true
+//	task: test
+//	context: my-team/ci
+//	deploy:
+//	  - master
+//	  - release/*
+//
+// Build disables all builds of the repository if it is false. Branches which match Deploy patterns
+// are deployed, other branches run Task ("test" by default). If Deploy is not set, deployed branches
+// are defined by the service rules. Context overrides the context name of commit statuses.
+type Config struct {
+	Build   bool
+	Task    string
+	Context string
+	Deploy  []string
+
+	deploy []*regexp.Regexp
+}
+
+// Parse parses pipeline configuration, absent keys get default values
+func Parse(data []byte) (*Config, error) {
+	values, err := yaml.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Build: true, Task: cicd.TaskTest}
+
+	for key, value := range values {
+		switch key {
+		case "build":
+			s, err := scalar(key, value)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Build, err = strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be true or false, got %q", key, s)
+			}
+
+		case "task":
+			cfg.Task, err = scalar(key, value)
+			if err != nil {
+				return nil, err
+			}
+			if cfg.Task != cicd.TaskTest && cfg.Task != cicd.TaskDeploy {
+				return nil, fmt.Errorf("%s must be %s or %s, got %q", key, cicd.TaskTest, cicd.TaskDeploy, cfg.Task)
+			}
+
+		case "context":
+			cfg.Context, err = scalar(key, value)
+			if err != nil {
+				return nil, err
+			}
+
+		case "deploy":
+			switch v := value.(type) {
+			case []string:
+				cfg.Deploy = v
+			case string:
+				// a single branch may be set without list
+				cfg.Deploy = []string{v}
+			}
+			if cfg.Deploy == nil {
+				cfg.Deploy = []string{}
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	for _, pattern := range cfg.Deploy {
+		cfg.deploy = append(cfg.deploy, GlobRegexp(pattern))
+	}
+
+	return cfg, nil
+}
+
+// HasDeployBranches returns true if deployed branches are defined by the repository
+func (c *Config) HasDeployBranches() bool {
+	return c.Deploy != nil
+}
+
+// Deploys checks if the branch matches one of Deploy patterns
+func (c *Config) Deploys(branch string) bool {
+	for _, re := range c.deploy {
+		if re.MatchString(branch) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GlobRegexp converts glob pattern into anchored regular expression,
+// "*" matches any sequence of characters (including "/"), "?" matches one character
+func GlobRegexp(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.Replace(expr, `\*`, ".*", -1)
+	expr = strings.Replace(expr, `\?`, ".", -1)
+
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+func scalar(key string, value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a single value, not a list", key)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/k8s-community/cicd"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Config
+	}{
+		{"defaults", "", Config{Build: true, Task: cicd.TaskTest}},
+		{"all keys", "build: true\ntask: deploy\ncontext: my-team/ci\ndeploy:\n  - master\n  - release/*\n",
+			Config{Build: true, Task: cicd.TaskDeploy, Context: "my-team/ci", Deploy: []string{"master", "release/*"}}},
+		{"disabled", "build: false # no builds\n", Config{Build: false, Task: cicd.TaskTest}},
+		{"single deploy branch", "deploy: master\n", Config{Build: true, Task: cicd.TaskTest, Deploy: []string{"master"}}},
+		{"no deploy branches", "deploy: []\n", Config{Build: true, Task: cicd.TaskTest, Deploy: []string{}}},
+		{"quoted context", `context: "ci # main"`, Config{Build: true, Task: cicd.TaskTest, Context: "ci # main"}},
+	}
+
+	for _, test := range tests {
+		cfg, err := Parse([]byte(test.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+
+		cfg.deploy = nil
+		if !reflect.DeepEqual(*cfg, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, *cfg)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"bad build", "build: maybe\n", `build must be true or false, got "maybe"`},
+		{"bad task", "task: release\n", `task must be test or deploy, got "release"`},
+		{"list task", "task: [test]\n", "task must be a single value, not a list"},
+		{"unknown key", "branches: [master]\n", `unknown key "branches"`},
+		{"nested map", "deploy:\n  branch: master\n", "nested keys are not supported"},
+		{"invalid YAML", "build true\n", `expected "key: value"`},
+	}
+
+	for _, test := range tests {
+		_, err := Parse([]byte(test.input))
+		if err == nil {
+			t.Errorf("%s: expected error %q", test.name, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error %q, got %q", test.name, test.err, err)
+		}
+	}
+}
+
+func TestDeploys(t *testing.T) {
+	cfg, err := Parse([]byte("deploy: [master, release/*]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for branch, expected := range map[string]bool{
+		"master":          true,
+		"master2":         false,
+		"release/1.0":     true,
+		"release/1.0/fix": true,
+		"feature/release": false,
+	} {
+		if cfg.Deploys(branch) != expected {
+			t.Errorf("Deploys(%q) must be %t", branch, expected)
+		}
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{"master", "master", true},
+		{"master", "master-2", false},
+		{"*", "feature/x", true},
+		{"release/*", "release/1.0", true},
+		{"release/*", "release", false},
+		{"v*", "v1.2.3", true},
+		{"v*", "release/v1", false},
+		{"v?", "v1", true},
+		{"v?", "v10", false},
+		{"v1.*", "v1x2", false},
+		{"feature/[x]", "feature/[x]", true},
+		{"feature/[x]", "feature/x", false},
+		{"a+b", "a+b", true},
+		{"a+b", "aab", false},
+	}
+
+	for _, test := range tests {
+		if got := GlobRegexp(test.pattern).MatchString(test.value); got != test.match {
+			t.Errorf("%q matches %q: expected %t, got %t", test.pattern, test.value, test.match, got)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package secrets loads secrets from settings or from files, e.g. mounted Kubernetes secrets.
+// Secrets loaded from files are reloaded by Watcher when the files are changed.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileSuffix is the suffix of environment variables which point to files of secrets
+const FileSuffix = "_FILE"
+
+// Secret is a value which may be rotated while the service is running
+type Secret struct {
+	name string
+	file string
+
+	// validate checks a value before it's used, invalid values are rejected. It may be nil.
+	validate func(value []byte) error
+
+	// Overlap is how long the previous value is accepted after rotation
+	Overlap time.Duration
+
+	mu        sync.RWMutex
+	value     []byte
+	previous  []byte
+	rotatedAt time.Time
+	onChange  []func(value []byte)
+}
+
+// New creates the secret from the value or reads it from the file, only one of them may be set.
+// The name is the environment variable of the secret, it's used in errors. validate checks the initial value
+// and reloaded ones, it may be nil.
+func New(name, value, file string, validate func(value []byte) error) (*Secret, error) {
+	s := &Secret{name: name, file: file, validate: validate}
+
+	switch {
+	case file != "" && value != "":
+		return nil, fmt.Errorf("only one of %s and %s%s may be set", name, name, FileSuffix)
+
+	case file != "":
+		value, err := s.read()
+		if err != nil {
+			return nil, err
+		}
+		s.value = value
+
+	case value != "":
+		if validate != nil {
+			if err := validate([]byte(value)); err != nil {
+				return nil, fmt.Errorf("%s is invalid: %s", name, err)
+			}
+		}
+		s.value = []byte(value)
+
+	default:
+		return nil, fmt.Errorf("%s or %s%s was not set", name, name, FileSuffix)
+	}
+
+	return s, nil
+}
+
+// File returns the file of the secret, it's empty if the secret is set by value
+func (s *Secret) File() string {
+	return s.file
+}
+
+// Value returns the current value
+func (s *Secret) Value() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.value
+}
+
+// Values returns the current value and the previous one if it's rotated within the overlap window
+func (s *Secret) Values() [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := [][]byte{s.value}
+	if s.previous != nil && time.Since(s.rotatedAt) < s.Overlap {
+		values = append(values, s.previous)
+	}
+
+	return values
+}
+
+// OnChange registers a function which is called with the new value after rotation
+func (s *Secret) OnChange(fn func(value []byte)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onChange = append(s.onChange, fn)
+}
+
+// Reload reads the file of the secret and rotates the value if the content is changed.
+// Secrets set by value aren't changed.
+func (s *Secret) Reload() (changed bool, err error) {
+	if s.file == "" {
+		return false, nil
+	}
+
+	value, err := s.read()
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	if bytes.Equal(value, s.value) {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.previous = s.value
+	s.value = value
+	s.rotatedAt = time.Now()
+	callbacks := s.onChange
+	s.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(value)
+	}
+
+	return true, nil
+}
+
+func (s *Secret) read() ([]byte, error) {
+	data, err := ioutil.ReadFile(s.file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read %s from %s: %s", s.name, s.file, err)
+	}
+
+	// editors and kubectl usually add a trailing newline
+	value := []byte(strings.TrimSpace(string(data)))
+	if len(value) == 0 {
+		return nil, fmt.Errorf("%s file %s is empty", s.name, s.file)
+	}
+
+	if s.validate != nil {
+		if err := s.validate(value); err != nil {
+			return nil, fmt.Errorf("%s from %s is invalid: %s", s.name, s.file, err)
+		}
+	}
+
+	return value, nil
+}
+
+// Watcher reloads secrets from files periodically. Kubernetes updates mounted secrets
+// by swapping symlinks, so the content is compared instead of watching file events.
+type Watcher struct {
+	interval time.Duration
+	secrets  []*Secret
+
+	// ErrorLog is called when a secret can't be reloaded, the current value is kept. It may be nil.
+	ErrorLog func(format string, args ...interface{})
+}
+
+// NewWatcher creates a watcher of the secrets which are loaded from files
+func NewWatcher(interval time.Duration, secrets ...*Secret) *Watcher {
+	w := &Watcher{interval: interval}
+	for _, s := range secrets {
+		if s.File() != "" {
+			w.secrets = append(w.secrets, s)
+		}
+	}
+
+	return w
+}
+
+// Run reloads the secrets until ctx is done
+func (w *Watcher) Run(ctx context.Context) {
+	if len(w.secrets) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, s := range w.secrets {
+			if _, err := s.Reload(); err != nil && w.ErrorLog != nil {
+				w.ErrorLog("couldn't reload secret, the current value is used: %s", err)
+			}
+		}
+	}
+}
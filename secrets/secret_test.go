@@ -0,0 +1,208 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// validate accepts values which start with "key"
+func validate(value []byte) error {
+	if !bytes.HasPrefix(value, []byte("key")) {
+		return errors.New("not a key")
+	}
+	return nil
+}
+
+func writeFile(t *testing.T, file, content string) {
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// accepted checks if the value is one of the values of the secret
+func accepted(s *Secret, value string) bool {
+	for _, v := range s.Values() {
+		if string(v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNew(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, file, "key1\n")
+
+	tests := []struct {
+		value, file string
+		expected    string
+	}{
+		{"key0", "", "key0"},
+		// a trailing newline of the file is trimmed
+		{"", file, "key1"},
+		{"key0", file, ""},
+		{"", "", ""},
+		{"invalid", "", ""},
+		{"", filepath.Join(t.TempDir(), "missing"), ""},
+	}
+
+	for _, test := range tests {
+		s, err := New("SECRET", test.value, test.file, validate)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%q, %q: expected error, got %q", test.value, test.file, s.Value())
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q, %q: %s", test.value, test.file, err)
+		} else if string(s.Value()) != test.expected {
+			t.Errorf("%q, %q: expected %q, got %q", test.value, test.file, test.expected, s.Value())
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, file, "key1")
+
+	s, err := New("SECRET", "", file, validate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Overlap = time.Minute
+
+	var changes []string
+	s.OnChange(func(value []byte) {
+		changes = append(changes, string(value))
+	})
+
+	if changed, err := s.Reload(); changed || err != nil {
+		t.Fatalf("unchanged file must not rotate the secret, got %t, %v", changed, err)
+	}
+
+	writeFile(t, file, "key2\n")
+	if changed, err := s.Reload(); !changed || err != nil {
+		t.Fatalf("changed file must rotate the secret, got %t, %v", changed, err)
+	}
+	if string(s.Value()) != "key2" || len(changes) != 1 || changes[0] != "key2" {
+		t.Errorf("expected key2, got %q and changes %q", s.Value(), changes)
+	}
+
+	// the previous value is accepted during the overlap
+	if !accepted(s, "key2") || !accepted(s, "key1") {
+		t.Errorf("expected key2 and key1 during the overlap, got %q", s.Values())
+	}
+
+	// an invalid or empty file keeps the current value
+	for _, content := range []string{"invalid", "\n"} {
+		writeFile(t, file, content)
+		if changed, err := s.Reload(); changed || err == nil {
+			t.Errorf("%q: expected error, got %t", content, changed)
+		}
+		if string(s.Value()) != "key2" || !accepted(s, "key1") {
+			t.Errorf("%q: expected key2 and key1, got %q", content, s.Values())
+		}
+	}
+
+	// the previous value expires after the overlap
+	s.mu.Lock()
+	s.rotatedAt = time.Now().Add(-s.Overlap)
+	s.mu.Unlock()
+	if accepted(s, "key1") || !accepted(s, "key2") {
+		t.Errorf("expected only key2 after the overlap, got %q", s.Values())
+	}
+
+	// no overlap, the previous value isn't accepted at all
+	s.Overlap = 0
+	writeFile(t, file, "key3")
+	if _, err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if accepted(s, "key2") || !accepted(s, "key3") {
+		t.Errorf("expected only key3 without overlap, got %q", s.Values())
+	}
+}
+
+func TestReloadValue(t *testing.T) {
+	s, err := New("SECRET", "key1", "", validate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := s.Reload(); changed || err != nil {
+		t.Errorf("secret set by value must not be reloaded, got %t, %v", changed, err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "secret")
+	writeFile(t, file, "key1")
+
+	s, err := New("SECRET", "", file, validate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byValue, err := New("VALUE", "key0", "", validate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWatcher(10*time.Millisecond, s, byValue)
+	if len(w.secrets) != 1 {
+		t.Fatalf("only secrets loaded from files must be watched, got %d", len(w.secrets))
+	}
+
+	var (
+		mu       sync.Mutex
+		failures int
+	)
+	w.ErrorLog = func(format string, args ...interface{}) {
+		mu.Lock()
+		failures++
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// eventually waits until the condition is true
+	eventually := func(condition func() bool) bool {
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+			if condition() {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+
+	writeFile(t, file, "invalid")
+	if !eventually(func() bool { mu.Lock(); defer mu.Unlock(); return failures > 0 }) {
+		t.Fatal("invalid file must be reported")
+	}
+	if string(s.Value()) != "key1" {
+		t.Errorf("invalid file must keep key1, got %q", s.Value())
+	}
+
+	writeFile(t, file, "key2")
+	if !eventually(func() bool { return string(s.Value()) == "key2" }) {
+		t.Errorf("watcher must reload key2, got %q", s.Value())
+	}
+}
@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gopkg.in/reform.v1"
+)
+
+// DBLogger is reform logger which records DB queries as spans of the context and passes queries to the next logger
+type DBLogger struct {
+	ctx  context.Context
+	next reform.Logger
+}
+
+// NewDBLogger creates DBLogger for the context, next may be nil
+func NewDBLogger(ctx context.Context, next reform.Logger) *DBLogger {
+	return &DBLogger{ctx: ctx, next: next}
+}
+
+// Before implements reform.Logger interface
+func (l *DBLogger) Before(query string, args []interface{}) {
+	if l.next != nil {
+		l.next.Before(query, args)
+	}
+}
+
+// After implements reform.Logger interface
+func (l *DBLogger) After(query string, args []interface{}, d time.Duration, err error) {
+	operation := "query"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+
+	// arguments aren't recorded, they may contain payloads and secrets
+	_, span := Start(l.ctx, "db "+operation, KindClient)
+	span.Start = span.Start.Add(-d)
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.statement", query)
+	span.SetError(err)
+	span.End()
+
+	if l.next != nil {
+		l.next.After(query, args, d, err)
+	}
+}
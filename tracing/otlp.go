@@ -0,0 +1,288 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// exportQueueSize is how many finished spans wait for export, new spans are dropped if the queue is full
+	exportQueueSize = 2048
+
+	// exportBatchSize is the max count of spans sent at once
+	exportBatchSize = 256
+
+	// exportInterval is how often spans are sent if the batch isn't full
+	exportInterval = 5 * time.Second
+
+	// exportTimeout limits time of sending a batch
+	exportTimeout = 10 * time.Second
+)
+
+// Status codes of OTLP spans, status of successful spans is left unset as OpenTelemetry recommends
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+var exporter struct {
+	sync.RWMutex
+	e *Exporter
+}
+
+// SetExporter sets the exporter of finished spans, spans aren't exported if it's nil
+func SetExporter(e *Exporter) {
+	exporter.Lock()
+	defer exporter.Unlock()
+
+	exporter.e = e
+}
+
+func currentExporter() *Exporter {
+	exporter.RLock()
+	defer exporter.RUnlock()
+
+	return exporter.e
+}
+
+// Exporter sends finished spans in batches to OTLP/HTTP collector in JSON encoding
+type Exporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	// ErrorLog is called when a batch can't be sent, it may be nil
+	ErrorLog func(format string, args ...interface{})
+
+	spans    chan *Span
+	quit     chan struct{}
+	quitOnce sync.Once
+	done     chan struct{}
+}
+
+// NewExporter creates an exporter and starts sending of spans in background,
+// endpoint is the URL of traces, e.g. http://otel-collector:4318/v1/traces
+func NewExporter(endpoint, service string) *Exporter {
+	e := &Exporter{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: exportTimeout},
+		spans:    make(chan *Span, exportQueueSize),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go e.loop()
+
+	return e
+}
+
+// Shutdown sends spans which are in the queue and stops the exporter, it may be called more than once
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.quitOnce.Do(func() { close(e.quit) })
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) export(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		// the collector is too slow or unavailable, tracing must not slow down the service
+	}
+}
+
+func (e *Exporter) loop() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) < exportBatchSize {
+				continue
+			}
+
+		case <-ticker.C:
+
+		case <-e.quit:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+					continue
+				default:
+				}
+				break
+			}
+			e.send(batch)
+			return
+		}
+
+		e.send(batch)
+		batch = nil
+	}
+}
+
+func (e *Exporter) send(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(e.request(batch))
+	if err == nil {
+		var resp *http.Response
+		resp, err = e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("collector responds %s", resp.Status)
+			}
+		}
+	}
+
+	if err != nil && e.ErrorLog != nil {
+		e.ErrorLog("couldn't export %d spans to %s: %s", len(batch), e.endpoint, err)
+	}
+}
+
+// OTLP JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+func (e *Exporter) request(batch []*Span) *otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, otlpSpanOf(s))
+	}
+
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpAttribute{attribute("service.name", e.service)}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: e.service}, Spans: spans}},
+	}}}
+}
+
+func otlpSpanOf(s *Span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		TraceID:           s.Context.TraceID.String(),
+		SpanID:            s.Context.SpanID.String(),
+		Name:              s.Name,
+		Kind:              s.Kind,
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Status:            otlpStatus{Code: statusUnset},
+	}
+
+	if s.Parent != (SpanID{}) {
+		span.ParentSpanID = s.Parent.String()
+	}
+
+	keys := make([]string, 0, len(s.attributes))
+	for key := range s.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		span.Attributes = append(span.Attributes, attribute(key, s.attributes[key]))
+	}
+
+	if s.err != nil {
+		span.Status = otlpStatus{Code: statusError, Message: s.err.Error()}
+	}
+
+	return span
+}
+
+func attribute(key string, value interface{}) otlpAttribute {
+	a := otlpAttribute{Key: key}
+
+	switch v := value.(type) {
+	case string:
+		a.Value.StringValue = &v
+	case bool:
+		a.Value.BoolValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		a.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		a.Value.IntValue = &s
+	case float64:
+		a.Value.DoubleValue = &v
+	default:
+		s := fmt.Sprint(v)
+		a.Value.StringValue = &s
+	}
+
+	return a
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOTLPRequest(t *testing.T) {
+	sc, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	start := time.Unix(1700000000, 123)
+
+	root := &Span{Name: "webhook", Kind: KindServer, Context: sc, Start: start, end: start.Add(time.Second)}
+	root.SetAttribute("http.status_code", 202)
+	root.SetAttribute("github.event", "push")
+	root.SetAttribute("retry", false)
+	root.SetAttribute("ratio", 0.5)
+
+	child := &Span{Name: "GitHub GET", Kind: KindClient, Start: start, end: start.Add(time.Millisecond)}
+	child.Context = SpanContext{TraceID: sc.TraceID, SpanID: SpanID{1, 2, 3, 4, 5, 6, 7, 8}, Sampled: true}
+	child.Parent = sc.SpanID
+	child.SetError(errors.New("github: not found"))
+
+	e := &Exporter{service: "github-integration"}
+	body, err := json.Marshal(e.request([]*Span{root, child}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"github-integration"}}]},` +
+		`"scopeSpans":[{"scope":{"name":"github-integration"},"spans":[` +
+		`{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7","name":"webhook","kind":2,` +
+		`"startTimeUnixNano":"1700000000000000123","endTimeUnixNano":"1700000001000000123","attributes":[` +
+		`{"key":"github.event","value":{"stringValue":"push"}},` +
+		`{"key":"http.status_code","value":{"intValue":"202"}},` +
+		`{"key":"ratio","value":{"doubleValue":0.5}},` +
+		`{"key":"retry","value":{"boolValue":false}}],"status":{"code":0}},` +
+		`{"traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"0102030405060708","parentSpanId":"00f067aa0ba902b7",` +
+		`"name":"GitHub GET","kind":3,"startTimeUnixNano":"1700000000000000123","endTimeUnixNano":"1700000000001000123",` +
+		`"status":{"code":2,"message":"github: not found"}}]}]}]}`
+
+	if string(body) != expected {
+		t.Errorf("unexpected request:\n%s\nexpected:\n%s", body, expected)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []otlpRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+
+		var req otlpRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			t.Errorf("invalid request: %s", err)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %s", r.Header.Get("Content-Type"))
+		}
+
+		mu.Lock()
+		bodies = append(bodies, req)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	e := NewExporter(srv.URL, "test")
+	SetExporter(e)
+	defer SetExporter(nil)
+
+	ctx, parent := Start(context.Background(), "parent", KindServer)
+	_, child := Start(ctx, "child", KindInternal)
+	child.End()
+	parent.End()
+	// spans are exported once
+	parent.End()
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("second shutdown must succeed, got %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	var names []string
+	for _, req := range bodies {
+		for _, span := range req.ResourceSpans[0].ScopeSpans[0].Spans {
+			names = append(names, span.Name)
+		}
+	}
+	if len(names) != 2 || names[0] != "child" || names[1] != "parent" {
+		t.Errorf("queued spans must be sent on shutdown, got %v", names)
+	}
+}
@@ -0,0 +1,252 @@
+// Package tracing implements spans with W3C trace context propagation and OTLP export.
+// Spans are exported only if an exporter is set, trace context is propagated anyway.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderTraceParent is the W3C trace context header
+const HeaderTraceParent = "traceparent"
+
+// Kinds of spans
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns hex representation of the trace ID
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// String returns hex representation of the span ID
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of span which is propagated between services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid checks if the trace ID and the span ID are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// TraceParent formats the span context as traceparent header value, it's empty for invalid span context
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceParent parses traceparent header value, ok is false if the value is invalid
+func ParseTraceParent(value string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, false
+	}
+
+	// fields are lowercase hex, uppercase values are invalid
+	for _, part := range parts[:4] {
+		if !isLowerHex(part) {
+			return sc, false
+		}
+	}
+
+	// future versions may add fields, version 00 has exactly 4 ones
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	return sc, sc.IsValid()
+}
+
+// Span is a timed operation of a trace
+type Span struct {
+	Name    string
+	Kind    int
+	Context SpanContext
+	Parent  SpanID
+	Start   time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes map[string]interface{}
+	err        error
+}
+
+// SetAttribute sets an attribute of the span, value is a string, a bool or a number
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[key] = value
+}
+
+// SetError marks the span as failed, nil error is ignored
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// End finishes the span and passes it to the exporter
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	ended := !s.end.IsZero()
+	if !ended {
+		s.end = time.Now()
+	}
+	s.mu.Unlock()
+
+	if !ended && s.Context.Sampled {
+		if e := currentExporter(); e != nil {
+			e.export(s)
+		}
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithSpan returns a copy of ctx with the span as the current one
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// ContextWithRemote returns a copy of ctx with the span context received from another service
+// (or stored earlier), it replaces the current span as the parent of spans started with the returned context
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	ctx = context.WithValue(ctx, spanKey{}, (*Span)(nil))
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanFromContext returns the current span, it's nil if there is no one
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns context of the current span or the remote span context
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.Context
+	}
+
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// Start starts a span which is a child of the current span of ctx, a new trace is started if there is no one
+func Start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	span := &Span{Name: name, Kind: kind, Start: time.Now()}
+	span.Context.SpanID = newSpanID()
+
+	if parent.IsValid() {
+		span.Context.TraceID = parent.TraceID
+		span.Context.Sampled = parent.Sampled
+		span.Parent = parent.SpanID
+	} else {
+		span.Context.TraceID = newTraceID()
+		span.Context.Sampled = currentExporter() != nil
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Inject sets traceparent header of outbound request to the current span of ctx
+func Inject(ctx context.Context, header http.Header) {
+	if value := SpanContextFromContext(ctx).TraceParent(); value != "" {
+		header.Set(HeaderTraceParent, value)
+	}
+}
+
+// Extract returns a copy of ctx with the remote span context of inbound request, if the request has it
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceParent(header.Get(HeaderTraceParent))
+	if !ok {
+		return ctx
+	}
+
+	return ContextWithRemote(ctx, sc)
+}
+
+func isLowerHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func newTraceID() (id TraceID) {
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		value   string
+		valid   bool
+		trace   string
+		span    string
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", false},
+		{" 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01 ", true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true},
+		// other flags are ignored
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03", true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true},
+		// future versions may have more fields
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true},
+
+		{"", false, "", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, "", "", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, "", "", false},
+		{"0x-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, "", "", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01", false, "", "", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, "", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, "", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false, "", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b-01", false, "", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1", false, "", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01", false, "", "", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, "", "", false},
+	}
+
+	for _, test := range tests {
+		sc, ok := ParseTraceParent(test.value)
+		if ok != test.valid {
+			t.Errorf("%q: expected valid %t, got %t", test.value, test.valid, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		if sc.TraceID.String() != test.trace || sc.SpanID.String() != test.span || sc.Sampled != test.sampled {
+			t.Errorf("%q: unexpected span context %s %s %t", test.value, sc.TraceID, sc.SpanID, sc.Sampled)
+		}
+	}
+}
+
+func TestTraceParent(t *testing.T) {
+	sc, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if got := sc.TraceParent(); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("unexpected traceparent %s", got)
+	}
+
+	sc.Sampled = false
+	if got := sc.TraceParent(); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00" {
+		t.Errorf("unexpected traceparent %s", got)
+	}
+
+	if got := (SpanContext{}).TraceParent(); got != "" {
+		t.Errorf("invalid span context must have empty traceparent, got %s", got)
+	}
+}
+
+func TestPropagation(t *testing.T) {
+	inbound := http.Header{}
+	inbound.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx := Extract(context.Background(), inbound)
+	ctx, span := Start(ctx, "child", KindServer)
+
+	if span.Context.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || !span.Context.Sampled {
+		t.Errorf("span doesn't continue the remote trace: %s", span.Context.TraceParent())
+	}
+	if span.Parent.String() != "00f067aa0ba902b7" {
+		t.Errorf("remote span must be the parent, got %s", span.Parent)
+	}
+
+	outbound := http.Header{}
+	Inject(ctx, outbound)
+	if got := outbound.Get(HeaderTraceParent); got != span.Context.TraceParent() {
+		t.Errorf("injected %s, expected %s", got, span.Context.TraceParent())
+	}
+}